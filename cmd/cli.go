@@ -5,16 +5,37 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	g "github.com/egregors/minesweeper/pkg"
+	"github.com/muesli/reflow/ansi"
 	"github.com/muesli/termenv"
 
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // noop is a No Operation event just to update UI
 type noop struct{}
 
+// tick is sent to UI every second to refresh countdowns
+type tick time.Time
+
+func tickEverySecond() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return tick(t)
+	})
+}
+
+// countdown formats time left until the deadline of the model, e.g. "25s"
+func countdown(m *g.Model, t time.Time) string {
+	left := t.Sub(m.Now()).Round(time.Second)
+	if left < 0 {
+		left = 0
+	}
+	return left.String()
+}
+
 var (
 	color      = termenv.EnvColorProfile().Color
 	oneMines   = termenv.Style{}.Foreground(color("4")).Styled
@@ -58,6 +79,173 @@ func styled(r rune) string {
 	return s
 }
 
+// styledMark returns the marker of the cell colored by its owner or "" if the
+// cell isn't marked. Markers of the own player win over others.
+func styledMark(m *g.Model, p g.Point, own string) string {
+	if mark := m.Mark(own, p); mark != g.HIDE {
+		return playerStyle(own)(string(mark))
+	}
+
+	ids := make([]string, 0, len(m.Marks))
+	for id := range m.Marks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if mark := m.Mark(id, p); mark != g.HIDE {
+			return playerStyle(id)(string(mark))
+		}
+	}
+	return ""
+}
+
+// lobbyFrame shows players of the lobby with their votes, the chosen board
+// and the countdown. The own player is marked.
+func lobbyFrame(m *g.Model, own string) string {
+	lines := []string{"", "Lobby: vote for the board and get ready", ""}
+	for _, id := range m.Players {
+		ready := RedStyle("not ready")
+		if m.Ready[id] {
+			ready = GreenStyle("ready")
+		}
+		name := playerStyle(id)(id)
+		if id == own {
+			name += " (you)"
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s, votes for %s", name, ready, m.Votes[id]))
+	}
+
+	lines = append(lines, "", "Board: "+m.Choice.String())
+	if m.StartsAt.IsZero() {
+		lines = append(lines, "Waiting for everybody to get ready...")
+	} else {
+		lines = append(lines, "Starting in "+countdown(m, m.StartsAt))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// teamFrame shows the result of the COOP game and the contribution of each
+// player: cells opened by the player
+func teamFrame(m *g.Model) string {
+	result := GreenStyle("🎉 TEAM WINS! 🎉")
+	if m.State == g.OVER {
+		result = RedStyle("💥 TEAM LOST 💥")
+	}
+
+	total := 0
+	for _, id := range m.Players {
+		total += m.Opened[id]
+	}
+	lines := []string{"", result, "Contribution:"}
+	for _, id := range m.Players {
+		share := 0
+		if total > 0 {
+			share = m.Opened[id] * 100 / total
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %d cells opened (%d%%)", playerStyle(id)(id), m.Opened[id], share))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// raceFrame shows how much of the private board each racer has opened
+func raceFrame(m *g.Model, own string) string {
+	total := m.N*m.M - m.MinesCount
+	lines := []string{"", "Race:"}
+	for _, id := range m.Players {
+		name := playerStyle(id)(id)
+		if id == own {
+			name += " (you)"
+		}
+		line := fmt.Sprintf("  %s %s", name, progressBar(total-m.Progress[id], total, 20))
+		if m.IsEliminated(id) {
+			line += " " + RedStyle("OUT")
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// progressBar draws the done part of the total, e.g. "[####------] 40%"
+func progressBar(done, total, width int) string {
+	percent := 0
+	if total > 0 {
+		percent = done * 100 / total
+	}
+	filled := percent * width / 100
+	return fmt.Sprintf("[%s%s] %d%%", strings.Repeat("#", filled), strings.Repeat("-", width-filled), percent)
+}
+
+// flagsFrame shows mines found by each player in FLAGS
+func flagsFrame(m *g.Model, own string) string {
+	lines := []string{"", fmt.Sprintf("Mines found (%d of %d to win):", m.MinesCount/2+1, m.MinesCount)}
+	for _, id := range m.Players {
+		name := playerStyle(id)(id)
+		if id == own {
+			name += " (you)"
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %d", name, m.Score[id]))
+	}
+	if m.IsOver() && m.Winner == "" {
+		lines = append(lines, "All mines are found, it's a draw")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// clockFrame shows the time left for the current move and chess clocks of
+// players, empty if moves have no time limit
+func clockFrame(m *g.Model) string {
+	if m.State != g.GAME && m.State != g.PAUSE {
+		return ""
+	}
+
+	var lines []string
+	if !m.TurnUntil.IsZero() {
+		lines = append(lines, "", "Move time left: "+countdown(m, m.TurnUntil))
+	}
+	if len(m.Clocks) > 0 {
+		clocks := make([]string, 0, len(m.Players))
+		for _, id := range m.Players {
+			clocks = append(clocks, playerStyle(id)(id)+" "+clockTime(m.ClockLeft(id)))
+		}
+		lines = append(lines, "Clocks: "+strings.Join(clocks, "  "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// clockTime formats the chess clock, e.g. "4:05"
+func clockTime(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// seriesFrame shows the scoreboard of the series and rematch requests after
+// the game is over
+func seriesFrame(m *g.Model) string {
+	if m.Series == nil {
+		return ""
+	}
+
+	lines := []string{"", "Series: " + m.Series.String()}
+	if m.Series.Winner != "" {
+		lines = append(lines, fmt.Sprintf("🏆 %s wins the series! 🏆", m.Series.Winner))
+	}
+	if m.Series.Draw {
+		lines = append(lines, "The series is a draw")
+	}
+	if m.IsOver() {
+		var asked []string
+		for _, id := range m.Players {
+			if m.Series.Rematch[id] {
+				asked = append(asked, id)
+			}
+		}
+		if len(asked) > 0 {
+			lines = append(lines, "Rematch asked by: "+strings.Join(asked, ", "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 type DebugModel interface {
 	tea.Model
 }
@@ -173,3 +361,73 @@ func LogsWidget(m LoggedModel, tail int) string {
 		strings.Join(logLines, ""),
 	}, "\n")
 }
+
+// chatWidth is the width of the chat pane, longer messages are wrapped
+const chatWidth = 40
+
+// ChatWidget shows the last chat messages and the message being typed
+func ChatWidget(msgs []g.ChatMessage, input string, typing bool, tail int) string {
+	var lines []string
+	for _, msg := range msgs {
+		prefix := msg.At.Local().Format("15:04") + " " + msg.From + ": "
+		text := []rune(prefix + msg.Text)
+		for first := true; len(text) > 0; first = false {
+			n := chatWidth
+			if n > len(text) {
+				n = len(text)
+			}
+			line := string(text[:n])
+			if first {
+				line = playerStyle(msg.From)(prefix) + string(text[len([]rune(prefix)):n])
+			}
+			lines = append(lines, line)
+			text = text[n:]
+		}
+	}
+	if len(lines) > tail {
+		lines = lines[len(lines)-tail:]
+	}
+
+	title := termenv.Style{}.Bold().Styled("CHAT:")
+	if typing {
+		lines = append(lines, "> "+lastRunes(input, chatWidth-3)+"_")
+	} else {
+		title += " (T to type)"
+	}
+	return strings.Join(append([]string{title}, lines...), "\n")
+}
+
+// lastRunes returns the end of the string fitting n runes
+func lastRunes(s string, n int) string {
+	rs := []rune(s)
+	if len(rs) > n {
+		rs = rs[len(rs)-n:]
+	}
+	return string(rs)
+}
+
+// sideBySide joins two frames in columns, the left one is padded to width
+func sideBySide(left, right string, width int) string {
+	ls := strings.Split(left, "\n")
+	rs := strings.Split(strings.TrimSuffix(right, "\n"), "\n")
+	n := len(ls)
+	if len(rs) > n {
+		n = len(rs)
+	}
+
+	lines := make([]string, n)
+	for i := range lines {
+		var l, r string
+		if i < len(ls) {
+			l = ls[i]
+		}
+		if i < len(rs) {
+			r = rs[i]
+		}
+		if pad := width - ansi.PrintableRuneWidth(l); pad > 0 {
+			l += strings.Repeat(" ", pad)
+		}
+		lines[i] = l + r
+	}
+	return strings.Join(lines, "\n")
+}
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -25,22 +27,42 @@ const (
 
 type Client struct {
 	serverAddr string
+	insecure   bool // skip TLS certificate verification, for self-signed servers
 	conn       net.Conn
+	token      string // session token, presented on reconnect to reclaim the slot
+	spectate   bool   // join as a spectator
+	resume     bool   // reclaim the slot of the saved session, after a restart
+	room       string // code of the room to join, set to the joined room by Welcome
+	create     bool   // create a new room
+	spectator  bool   // the server joined the client as a spectator
 
 	game *g.Game
+	seq  uint64          // sequence number of the last applied update
+	chat []g.ChatMessage // messages of the room chat, guarded by mu
 	ui   *tea.Program
 
+	// resync is true after a missed delta, until the full game is received
+	resync bool
+	// reconnecting is true while the client re-dials the lost server
+	reconnecting bool
+
 	state clientState
 
 	logger g.Logger
 	dbg    bool
 
-	mu sync.Mutex
+	mu  sync.Mutex
+	wmu sync.Mutex // guards writes to conn
 }
 
-func NewClient(serverAddr string, logger g.Logger, dbg bool) *Client {
+func NewClient(serverAddr string, insecure, spectate, resume bool, room string, create bool, logger g.Logger, dbg bool) *Client {
 	c := new(Client)
 	c.serverAddr = serverAddr
+	c.insecure = insecure
+	c.spectate = spectate
+	c.resume = resume
+	c.room = room
+	c.create = create
 
 	c.dbg = dbg
 	c.logger = logger
@@ -48,15 +70,123 @@ func NewClient(serverAddr string, logger g.Logger, dbg bool) *Client {
 	return c
 }
 
-func (c *Client) updateGame(data []byte) {
+// updateGame applies a message from the server. On a missed delta the client
+// asks the server for the full game and skips deltas until it arrives.
+func (c *Client) updateGame(msg *g.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch msg.Type {
+	case g.MsgState:
+		c.setGame(msg.State.Game)
+		c.game.M.SyncClock(msg.State.Now)
+		c.seq = msg.State.Seq
+		c.resync = false
+
+	case g.MsgDelta:
+		if c.resync {
+			return
+		}
+		d := msg.Delta
+		if c.game == nil || d.Seq != c.seq+1 {
+			log.Printf("Missed update #%d (got #%d), resync", c.seq+1, d.Seq)
+			c.resync = true
+			if err := c.send(g.NewEvent(g.Resync, g.Point{})); err != nil {
+				log.Printf("can't send resync request: %s", err.Error())
+			}
+			return
+		}
+		c.game.M.Apply(d)
+		c.seq = d.Seq
+
+	case g.MsgChat:
+		c.addChat(msg.Chat.Messages)
+
+	case g.MsgError:
+		log.Printf("Server error: %s", msg.Error)
+
+	default:
+		log.Printf("Unexpected %s message", msg.Type)
+	}
+}
+
+// addChat keeps new chat messages. The history is sent again on reconnect,
+// messages not newer than the last one kept are skipped.
+func (c *Client) addChat(msgs []g.ChatMessage) {
+	for _, msg := range msgs {
+		if n := len(c.chat); n > 0 && !msg.At.After(c.chat[n-1].At) {
+			continue
+		}
+		c.chat = append(c.chat, msg)
+	}
+	if len(c.chat) > chatHistory {
+		c.chat = c.chat[len(c.chat)-chatHistory:]
+	}
+}
+
+// chatLog returns a copy of chat messages for the UI
+func (c *Client) chatLog() []g.ChatMessage {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	g.FromGob(data, &c.game)
+	return append([]g.ChatMessage(nil), c.chat...)
+}
+
+// setGame replaces the game keeping the same Model pointer, which is shared with the UI
+func (c *Client) setGame(game *g.Game) {
+	if c.game == nil {
+		c.game = game
+		return
+	}
+
+	*c.game.M = *game.M
+	c.game.Difficulty = game.Difficulty
+	c.game.Seed = game.Seed
+	c.game.First = game.First
+}
+
+// send sends the event to the server
+func (c *Client) send(e *g.Event) error {
+	return c.write(g.NewEventMessage(e))
+}
+
+func (c *Client) write(m *g.Message) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return wsutil.WriteClientMessage(c.conn, ws.OpBinary, m.Bytes())
+}
+
+// read reads the next message. The server pings the client, so a server
+// silent for idleTimeout is dropped and the client reconnects.
+func (c *Client) read() (*g.Message, error) {
+	data, _, err := wsutil.ReadServerData(readConn{aliveConn{c.conn}, &c.wmu})
+	if err != nil {
+		return nil, err
+	}
+	return g.NewMessageFromBytes(data)
+}
+
+// readConn is the connection of the reader, it answers pings of the server
+// with pongs written under the write lock
+type readConn struct {
+	aliveConn
+	wmu *sync.Mutex
+}
+
+func (c readConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.aliveConn.Write(p)
 }
 
 func (c *Client) connect() error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
 	if c.conn == nil {
-		conn, _, _, err := ws.DefaultDialer.Dial(context.Background(), c.serverAddr)
+		dialer := ws.Dialer{
+			//nolint:gosec // it's an explicit user choice for self-signed servers
+			TLSConfig: &tls.Config{InsecureSkipVerify: c.insecure},
+		}
+		conn, _, _, err := dialer.Dial(context.Background(), c.serverAddr)
 		if err != nil {
 			return fmt.Errorf("can't conntect to the server: %e", err)
 		}
@@ -65,24 +195,66 @@ func (c *Client) connect() error {
 	return nil
 }
 
-func (c *Client) pullServerEvents() {
+// reconnect re-dials the server until the client rejoins the game with its
+// session token. Gives up only if the server rejects the client.
+func (c *Client) reconnect() error {
+	c.setReconnecting(true)
+	defer c.setReconnecting(false)
+
 	for {
-		// Check if game is over based on server data
-		if c.game != nil && c.game.M != nil && (c.game.M.State == g.OVER || c.game.M.State == g.WIN) {
-			stateStr := "OVER"
-			if c.game.M.State == g.WIN {
-				stateStr = "WIN"
+		c.wmu.Lock()
+		if c.conn != nil {
+			_ = c.conn.Close()
+			c.conn = nil
+		}
+		c.wmu.Unlock()
+
+		err := c.connect()
+		if err == nil {
+			if _, err = c.join(); err == nil {
+				log.Println("reconnected!")
+				return nil
+			}
+			// the server may not have noticed the lost connection yet, it
+			// drops a silent one within idleTimeout
+			var rejected *g.Error
+			if errors.As(err, &rejected) && rejected.Code != g.ErrSessionInUse {
+				return err
 			}
-			log.Printf("Game ended with state: %s, stopping event pulling...", stateStr)
-			c.ui.Send(noop{})
-			return
 		}
 
+		log.Printf("sleep before reconnect: %s", err.Error())
+		c.ui.Send(noop{})
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (c *Client) setReconnecting(v bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reconnecting = v
+}
+
+func (c *Client) isReconnecting() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reconnecting
+}
+
+// pullServerEvents applies updates from the server until the client is
+// rejected. Updates keep coming after the game is over, a rematch starts the
+// next game.
+func (c *Client) pullServerEvents() {
+	for {
 		switch c.state {
 		case GAME:
-			msg, _, err := wsutil.ReadServerData(c.conn)
+			msg, err := c.read()
 			if err != nil {
 				log.Printf("Can't receive data: %s", err.Error())
+				if err := c.reconnect(); err != nil {
+					log.Printf("Can't rejoin the game: %s", err.Error())
+					c.state = OVER
+				}
 				continue
 			}
 			c.updateGame(msg)
@@ -96,6 +268,35 @@ func (c *Client) pullServerEvents() {
 	}
 }
 
+// ListRooms prints open rooms of the server
+func (c *Client) ListRooms() error {
+	if err := c.connect(); err != nil {
+		return err
+	}
+	defer func() { _ = c.conn.Close() }()
+
+	if err := c.write(&g.Message{Type: g.MsgRooms, Rooms: &g.Rooms{}}); err != nil {
+		return fmt.Errorf("cannot ask for rooms: %w", err)
+	}
+	msg, err := c.read()
+	if err != nil {
+		return fmt.Errorf("cannot receive rooms: %w", err)
+	}
+	if msg.Type != g.MsgRooms {
+		return fmt.Errorf("expected rooms, got %s message", msg.Type)
+	}
+
+	if len(msg.Rooms.List) == 0 {
+		fmt.Println("No open rooms, start the client with --create to create one")
+		return nil
+	}
+	for _, r := range msg.Rooms.List {
+		fmt.Printf("%s  %s  players: %d/%d  spectators: %d  %s\n",
+			r.Code, r.Board, r.Players, r.MaxPlayers, r.Spectators, g.StateTitle(r.State))
+	}
+	return nil
+}
+
 func (c *Client) Run() error {
 	log.Println("Client started")
 	// connection retry loop
@@ -125,54 +326,23 @@ func (c *Client) Run() error {
 }
 
 func (c *Client) initGame() error {
-	// hi server message
-	if err := wsutil.WriteClientMessage(c.conn, ws.OpText, nil); err != nil {
-		return fmt.Errorf("cannot send initial message: %w", err)
-	}
-
-	// get player ID or error message
-	msg, op, err := wsutil.ReadServerData(c.conn)
+	playerID, err := c.join()
 	if err != nil {
-		return fmt.Errorf("cannot receive response: %w", err)
-	}
-
-	// Check if server sent a text message (player ID or error)
-	var playerID string
-	if op == ws.OpText {
-		textMsg := string(msg)
-		if strings.HasPrefix(textMsg, "LOBBY_FULL:") {
-			return fmt.Errorf("cannot join game: %s", textMsg[12:])
-		}
-		if strings.HasPrefix(textMsg, "PLAYER_ID:") {
-			playerID = textMsg[10:]
-			log.Printf("Assigned Player ID: %s", playerID)
-		} else {
-			return fmt.Errorf("server error: %s", textMsg)
-		}
-		
-		// Now get the game data
-		msg, op, err = wsutil.ReadServerData(c.conn)
-		if err != nil {
-			return fmt.Errorf("cannot receive game data: %w", err)
-		}
-		
-		if op != ws.OpBinary {
-			return fmt.Errorf("expected binary game data, got: %s", string(msg))
-		}
+		return err
 	}
 
 	// start game
 	c.state = GAME
-	c.updateGame(msg)
-	
+
 	c.ui = tea.NewProgram(clientUIModel{
 		Model:     c.game.M,
 		Conn:      c.conn,
-		Cur:       g.Point{},
+		Cur:       c.game.M.Cursors[playerID], // the server keeps the cursor of a reclaimed slot
 		Dbg:       c.dbg,
 		ShowDebug: c.dbg,
 		C:         c,
 		PlayerID:  playerID,
+		Spectator: c.spectator,
 	})
 
 	// pull game update from the server
@@ -182,6 +352,69 @@ func (c *Client) initGame() error {
 	return c.ui.Start()
 }
 
+// join makes the handshake with the server and receives the game. Returns
+// the player ID assigned by the server. Spectators don't keep session tokens.
+func (c *Client) join() (string, error) {
+	// the saved token is taken only on request: another client on the same
+	// machine may hold it. Reconnects use the token of this client.
+	if c.token == "" && c.resume && !c.spectate {
+		c.token = loadToken(c.serverAddr)
+	}
+
+	// hi server message
+	hello := g.NewHello(c.token, c.spectate)
+	hello.Hello.Room = c.room
+	hello.Hello.Create = c.create
+	if err := c.write(hello); err != nil {
+		return "", fmt.Errorf("cannot send initial message: %w", err)
+	}
+
+	// get player ID or error message
+	msg, err := c.read()
+	if err != nil {
+		return "", fmt.Errorf("cannot receive response (incompatible server?): %w", err)
+	}
+
+	var playerID string
+	switch msg.Type {
+	case g.MsgError:
+		return "", fmt.Errorf("cannot join game: %w", msg.Error)
+	case g.MsgWelcome:
+		if msg.Welcome.Version != g.ProtocolVersion {
+			return "", fmt.Errorf("server speaks protocol v%d, client v%d", msg.Welcome.Version, g.ProtocolVersion)
+		}
+		playerID = msg.Welcome.PlayerID
+		log.Printf("Assigned Player ID: %s, capabilities: %v", playerID, msg.Welcome.Capabilities)
+		log.Printf("Joined room %s", msg.Welcome.Room)
+		// rejoin the same room on reconnect
+		c.room = msg.Welcome.Room
+		c.create = false
+		c.spectator = msg.Welcome.Spectator
+		if c.spectator {
+			log.Print("The game is full, watching as a spectator")
+			break
+		}
+		c.token = msg.Welcome.Token
+		if err := saveToken(c.serverAddr, c.token); err != nil {
+			log.Printf("can't save session token: %s", err.Error())
+		}
+	default:
+		return "", fmt.Errorf("expected welcome, got %s message", msg.Type)
+	}
+
+	// Now get the game data
+	msg, err = c.read()
+	if err != nil {
+		return "", fmt.Errorf("cannot receive game data: %w", err)
+	}
+	if msg.Type != g.MsgState {
+		return "", fmt.Errorf("expected game state, got %s message", msg.Type)
+	}
+	c.updateGame(msg)
+
+	return playerID, nil
+}
+
 type clientUIModel struct {
 	*g.Model
 	Cur  g.Point
@@ -192,32 +425,60 @@ type clientUIModel struct {
 	Dbg       bool
 	ShowDebug bool   // Toggle for debug display
 	PlayerID  string // Player's own ID (P1 or P2)
+	Spectator bool   // spectators only watch the game
+	Typing    bool   // keys go to the chat message
+	Input     string // chat message being typed
 }
 
 func (m clientUIModel) Init() tea.Cmd {
-	return tea.EnterAltScreen
+	return tea.Batch(tea.EnterAltScreen, tickEverySecond())
 }
 
 func (m clientUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	// current cell on Field
-	c := m.Field[m.Cur[0]][m.Cur[1]]
-
 	switch msg := msg.(type) {
 	case noop:
-		// update UI
+		// update UI, the cursor may be out of a new board after the lobby
+		if !m.Inside(m.Cur) {
+			m.Cur = g.Point{}
+		}
 		return m, nil
 
+	case tick:
+		// refresh countdowns
+		return m, tickEverySecond()
+
 	case tea.KeyMsg:
-		// control
-		if m.State == g.WIN {
-			return m, tea.Quit
+		// spectators can't play, the server ignores their events anyway
+		if m.Spectator {
+			switch msg.Type {
+			case tea.KeyCtrlC:
+				return m, tea.Quit
+			case tea.KeyCtrlD:
+				m.ShowDebug = !m.ShowDebug
+			}
+			return m, nil
+		}
+
+		if m.Typing {
+			return m.updateChat(msg)
+		}
+		if msg.String() == "t" {
+			m.Typing = true
+			return m, nil
+		}
+
+		if m.State == g.LOBBY {
+			return m.updateLobby(msg)
+		}
+		if m.IsOver() {
+			return m.updateOver(msg)
 		}
 
 		// each Update client state should send this state on server
 		var eT g.EventType
 		defer func(eT *g.EventType) {
 			e := g.NewEvent(*eT, m.Cur)
-			if err := wsutil.WriteClientMessage(m.Conn, ws.OpBinary, e.Bytes()); err != nil {
+			if err := m.C.send(e); err != nil {
 				log.Printf("can't sent cur to server")
 			}
 		}(&eT)
@@ -259,14 +520,7 @@ func (m clientUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case tea.KeyEnter:
 			if m.State == g.GAME {
-				switch c {
-				case g.HIDE:
-					m.Field[m.Cur[0]][m.Cur[1]] = g.FLAG
-				case g.FLAG:
-					m.Field[m.Cur[0]][m.Cur[1]] = g.GESS
-				case g.GESS:
-					m.Field[m.Cur[0]][m.Cur[1]] = g.HIDE
-				}
+				eT = g.ToggleFlag
 			}
 
 		default:
@@ -292,19 +546,111 @@ func (m clientUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.Cur[1]++
 					eT = g.CursorMove
 				}
+			case "c":
+				if m.State == g.GAME {
+					eT = g.Chord
+				}
+			case "g":
+				if m.State == g.GAME {
+					eT = g.ToggleGuess
+				}
+			case "p":
+				eT = g.PingCell
 			}
 		}
 	}
 	return m, nil
 }
 
+// updateChat handles keys while the chat message is typed: Enter sends it,
+// Esc cancels
+func (m clientUIModel) updateChat(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.Typing, m.Input = false, ""
+	case tea.KeyEnter:
+		if strings.TrimSpace(m.Input) != "" {
+			if err := m.C.send(g.NewChatEvent(m.Input)); err != nil {
+				log.Printf("can't send chat message to server")
+			}
+		}
+		m.Typing, m.Input = false, ""
+	case tea.KeyBackspace:
+		if rs := []rune(m.Input); len(rs) > 0 {
+			m.Input = string(rs[:len(rs)-1])
+		}
+	case tea.KeySpace, tea.KeyRunes:
+		if len([]rune(m.Input))+len(msg.Runes) <= chatMaxLen {
+			m.Input += string(msg.Runes)
+		}
+	}
+	return m, nil
+}
+
+// updateLobby handles keys of the lobby: votes for the board and the ready check
+func (m clientUIModel) updateLobby(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	vote := m.Votes[m.PlayerID]
+	var e *g.Event
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "ctrl+d":
+		m.ShowDebug = !m.ShowDebug
+		return m, nil
+	case "1", "2", "3", "4":
+		vote.Difficulty = g.Difficulty(msg.Runes[0] - '1')
+		e = g.NewVoteEvent(vote)
+	case "n":
+		vote.NoGuess = !vote.NoGuess
+		e = g.NewVoteEvent(vote)
+	case "m":
+		vote.Mode = (vote.Mode + 1) % g.Mode(len(g.Modes()))
+		e = g.NewVoteEvent(vote)
+	case "r":
+		e = g.NewEvent(g.ToggleReady, m.Cur)
+	}
+
+	if e != nil {
+		if err := m.C.send(e); err != nil {
+			log.Printf("can't send lobby event to server")
+		}
+	}
+	return m, nil
+}
+
+// updateOver handles keys after the game is over: a rematch request or exit
+func (m clientUIModel) updateOver(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "ctrl+d":
+		m.ShowDebug = !m.ShowDebug
+	case "r":
+		if err := m.C.send(g.NewEvent(g.Rematch, m.Cur)); err != nil {
+			log.Printf("can't send rematch request to server")
+		}
+	case "p":
+		if err := m.C.send(g.NewEvent(g.PingCell, m.Cur)); err != nil {
+			log.Printf("can't send ping to server")
+		}
+	}
+	return m, nil
+}
+
 func (m clientUIModel) View() string {
+	field := m.fieldFrame()
+	if m.State == g.LOBBY {
+		field = lobbyFrame(m.Model, m.PlayerID)
+	}
 	frame := []string{
 		m.titleFrame(),
-		m.fieldFrame(),
+		field,
 		m.controlsFrame(),
 		m.statusFrame(),
-		LogsWidget(m, 5),
+		sideBySide(ChatWidget(m.C.chatLog(), m.Input, m.Typing, 5), LogsWidget(m, 5), chatWidth+2),
 	}
 
 	if m.ShowDebug {
@@ -337,11 +683,24 @@ func (m clientUIModel) fieldFrame() string {
 		var line string
 		for c := 0; c < m.M; c++ {
 			lo, hi := " ", " "
-			if m.Cur[0] == r && m.Cur[1] == c {
+			for id, cur := range m.Cursors {
+				if id != m.PlayerID && cur[0] == r && cur[1] == c {
+					style := playerStyle(id)
+					lo, hi = style("["), style("]")
+				}
+			}
+			if !m.Spectator && m.Cur[0] == r && m.Cur[1] == c {
 				lo, hi = "[", "]"
 			}
+			cell := styled(m.Field[r][c])
+			if mark := styledMark(m.Model, g.Point{r, c}, m.PlayerID); mark != "" {
+				cell = mark
+			}
+			if id := m.PingedBy(g.Point{r, c}); id != "" {
+				cell = pingStyle(id)(string(m.Field[r][c]))
+			}
 			line += lo
-			line += styled(m.Field[r][c])
+			line += cell
 			line += hi
 		}
 		lines = append(lines, line)
@@ -350,12 +709,54 @@ func (m clientUIModel) fieldFrame() string {
 }
 
 func (m clientUIModel) controlsFrame() string {
+	if m.State == g.LOBBY && !m.Spectator {
+		vote := "  Vote for the board: 1 EASY, 2 NORMAL, 3 HARD"
+		if m.C.game.Difficulty == g.CUSTOM {
+			vote += ", 4 CUSTOM"
+		}
+		return strings.Join([]string{
+			"",
+			"Controls:",
+			vote,
+			"  Vote for no-guess board: N",
+			"  Vote for the mode (versus, co-op, race, flags): M",
+			"  Ready: R",
+			"  Chat: T",
+			"  Quit: Ctrl+C",
+		}, "\n")
+	}
+
+	if m.Spectator {
+		return strings.Join([]string{
+			"",
+			"Controls:",
+			"  Toggle Debug: Ctrl+D",
+			"  Quit: Ctrl+C",
+		}, "\n")
+	}
+
+	if m.IsOver() {
+		return strings.Join([]string{
+			"",
+			"Controls:",
+			"  Rematch: R",
+			"  Ping the cell: P",
+			"  Chat: T",
+			"  Toggle Debug: Ctrl+D",
+			"  Quit: Q or Ctrl+C",
+		}, "\n")
+	}
+
 	controls := []string{
 		"",
 		"Controls:",
 		"  Move: Arrow Keys or WASD",
 		"  Open Cell: Space",
-		"  Flag/Guess: Enter",
+		"  Chord (open around a number): C",
+		"  Flag: Enter",
+		"  Guess: G",
+		"  Ping the cell: P",
+		"  Chat: T",
 		"  Toggle Debug: Ctrl+D",
 		"  Quit: Ctrl+C",
 	}
@@ -364,23 +765,62 @@ func (m clientUIModel) controlsFrame() string {
 
 func (m clientUIModel) statusFrame() string {
 	var status []string
-	
+
+	if m.C.game != nil && m.State != g.LOBBY {
+		// the seed gives the mine layout away, so the server sends it after the game only
+		seed := "hidden until the game ends"
+		if m.C.game.Seed != 0 {
+			seed = m.C.game.SeedCode()
+		}
+		status = append(status, "", "Board: "+m.C.game.Title(), "Mode: "+m.Mode.String(), "Seed: "+seed)
+	}
+
+	status = append(status, "", "Room: "+m.C.room)
+
+	if m.Spectator {
+		status = append(status, fmt.Sprintf("Watching the game as %s", m.PlayerID))
+	}
+
+	if m.C.isReconnecting() {
+		status = append(status, "", "Connection lost, reconnecting...")
+	}
+
+	// Show the reason of the pause
+	if m.State == g.PAUSE {
+		status = append(status, "", fmt.Sprintf("Game paused: %s, forfeit in %s", m.Paused, countdown(m.Model, m.PausedUntil)))
+	}
+
 	// Show current turn indicator during gameplay
-	if m.State == g.GAME {
+	if m.State == g.GAME && m.Mode == g.COOP {
+		status = append(status, "", "Co-op: everybody opens cells at any time")
+	} else if m.State == g.GAME && m.Mode == g.RACE {
+		status = append(status, "", "Race: clear your board first, a mine puts you out")
+	} else if m.State == g.GAME {
 		turnInfo := fmt.Sprintf("Current Turn: %s", m.CurrentTurn)
 		status = append(status, "", turnInfo)
+		if m.IsEliminated(m.PlayerID) {
+			status = append(status, "You hit a mine and are out of the game")
+		}
+	}
+
+	if len(m.Eliminated) > 0 {
+		status = append(status, "Out of the game: "+strings.Join(m.Eliminated, ", "))
 	}
 	
 	// Show game end status with winner
-	if m.State == g.WIN || m.State == g.OVER {
+	if m.IsOver() && m.Mode == g.COOP {
+		status = append(status, teamFrame(m.Model))
+	} else if m.State == g.WIN || m.State == g.OVER {
 		if m.Winner != "" {
 			winnerMsg := fmt.Sprintf("🎉 %s WINS! 🎉", m.Winner)
 			status = append(status, "", winnerMsg)
 			
-			// Show if you won or lost
-			if m.PlayerID == m.Winner {
+			// Show if you won or lost, spectators don't play
+			switch {
+			case m.Spectator:
+			case m.PlayerID == m.Winner:
 				status = append(status, "Congratulations! You won!")
-			} else {
+			default:
 				status = append(status, "Better luck next time!")
 			}
 		} else {
@@ -391,7 +831,20 @@ func (m clientUIModel) statusFrame() string {
 				status = append(status, "", "GAME OVER")
 			}
 		}
-		status = append(status, "Press any key to exit...")
+	}
+
+	if clock := clockFrame(m.Model); clock != "" {
+		status = append(status, clock)
+	}
+	if m.Mode == g.RACE && m.State != g.LOBBY {
+		status = append(status, raceFrame(m.Model, m.PlayerID))
+	}
+	if m.Mode == g.FLAGS && m.State != g.LOBBY {
+		status = append(status, flagsFrame(m.Model, m.PlayerID))
+	}
+
+	if series := seriesFrame(m.Model); series != "" {
+		status = append(status, series)
 	}
 	
 	return strings.Join(status, "\n")
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"log"
+	"time"
+
+	g "github.com/egregors/minesweeper/pkg"
+)
+
+const (
+	// clockTick is how often rooms check the move time limit
+	clockTick = 100 * time.Millisecond
+
+	TimeoutSkip    = "skip"    // a move out of time passes the turn
+	TimeoutForfeit = "forfeit" // a move out of time puts the player out of the game
+)
+
+// startTurn starts the clock of the current move. The move times out after
+// the move time limit or when the player's chess clock runs out.
+func (r *room) startTurn() {
+	m := r.game.M
+	if !m.Mode.HasTurns() || m.State != g.GAME || r.currentTurn == "" {
+		return
+	}
+
+	now := time.Now()
+	m.TurnStarted = now
+	m.TurnUntil = time.Time{}
+	if r.s.opts.TurnTime > 0 {
+		m.TurnUntil = now.Add(r.s.opts.TurnTime)
+	}
+	if r.s.opts.Clock > 0 {
+		flag := now.Add(m.Clocks[r.currentTurn])
+		if m.TurnUntil.IsZero() || flag.Before(m.TurnUntil) {
+			m.TurnUntil = flag
+		}
+	}
+}
+
+// stopTurn stops the clock of the current move, the time spent is charged to
+// the player's chess clock
+func (r *room) stopTurn() {
+	m := r.game.M
+	if m.TurnStarted.IsZero() {
+		return
+	}
+	if r.s.opts.Clock > 0 {
+		m.Clocks[r.currentTurn] = m.ClockLeft(r.currentTurn)
+	}
+	m.TurnStarted = time.Time{}
+	m.TurnUntil = time.Time{}
+}
+
+// watchClock times out moves until the room is closed, it runs only if moves
+// have a time limit
+func (r *room) watchClock() {
+	ticker := time.NewTicker(clockTick)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return
+		}
+		m := r.game.M
+		if m.State == g.GAME && !m.TurnUntil.IsZero() && time.Now().After(m.TurnUntil) {
+			r.timeout()
+		}
+		r.mu.Unlock()
+	}
+}
+
+// timeout ends the move out of time. The player whose chess clock has run
+// out is out of the game, a move over the time limit is skipped or forfeited
+// by the server options.
+func (r *room) timeout() {
+	id := r.currentTurn
+	r.stopTurn()
+
+	flagFell := r.s.opts.Clock > 0 && r.game.M.Clocks[id] <= 0
+	if !flagFell && r.s.opts.OnTimeout != TimeoutForfeit {
+		log.Printf("[%s] Player %s ran out of time, the turn is skipped", r.code, id)
+		r.switchTurn()
+		r.broadcast(r.game.Delta(nil, nil))
+		r.s.refresh(noop{})
+		return
+	}
+
+	cells := r.game.Eliminate(id)
+	if r.game.M.IsOver() {
+		log.Printf("[%s] Player %s ran out of time! Player %s wins!", r.code, id, r.game.M.Winner)
+		r.endRound()
+		r.broadcast(nil)
+		r.s.refresh(noop{})
+		return
+	}
+
+	log.Printf("[%s] Player %s ran out of time and is out of the game", r.code, id)
+	r.switchTurn()
+	r.broadcast(r.game.Delta(cells, nil))
+	r.s.refresh(noop{})
+}
@@ -0,0 +1,1207 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	g "github.com/egregors/minesweeper/pkg"
+)
+
+const (
+	// finishedRoomTTL is how long players may look at the result of the game
+	// before the room is removed
+	finishedRoomTTL = 5 * time.Minute
+
+	// lobbyCountdown is how long the lobby waits before the game once
+	// everybody is ready
+	lobbyCountdown = 5 * time.Second
+
+	// chatHistory is how many recent chat messages are sent to joined clients
+	chatHistory = 20
+
+	// chatMaxLen is the longest chat message in runes, longer ones are cut
+	chatMaxLen = 200
+
+	// pingCooldown is how often a player may ping a cell
+	pingCooldown = time.Second
+)
+
+// room is a game with its own players and spectators, joined by the room code
+type room struct {
+	code        string
+	s           *Srv
+	game        *g.Game
+	ps          players
+	specs       players            // spectators, they only watch the game
+	watchers    int                // spectators joined so far, numbers spectator IDs
+	currentTurn string             // ID of the player to move
+	seq         uint64             // sequence number of the last update sent to clients
+	countdown   *time.Timer        // starts the game once everybody in the lobby is ready
+	countdowns  int                // countdowns started, the start of an older one is stale
+	series      *g.Series          // scoreboard of the games played since the lobby
+	boards      map[string]*g.Game // private boards of players by ID, in RACE
+	laying      bool               // the board of the round is being laid out, moves wait for it
+	chat        []g.ChatMessage    // recent chat messages, for late joiners
+
+	created  time.Time
+	finished time.Time // when the game was found over by the cleanup
+	closed   bool      // the room is removed, nobody can join it
+
+	mu sync.Mutex
+}
+
+func newRoom(s *Srv, code string, game *g.Game) *room {
+	r := &room{
+		code:    code,
+		s:       s,
+		game:    game,
+		ps:      make(players),
+		specs:   make(players),
+		created: time.Now(),
+	}
+	r.game.M.State = g.LOBBY
+	r.game.M.Ready = make(map[string]bool)
+	r.game.M.Votes = make(map[string]g.Vote)
+	r.game.M.Choice = r.defaultVote()
+	if s.opts.TurnTime > 0 || s.opts.Clock > 0 {
+		go r.watchClock()
+	}
+	return r
+}
+
+// defaultVote is the board and the mode of the server settings
+func (r *room) defaultVote() g.Vote {
+	return g.Vote{Difficulty: r.s.settings.Difficulty, NoGuess: r.s.settings.NoGuess, Mode: r.s.settings.Mode}
+}
+
+// checkVote validates the server settings with the board and the mode of the
+// vote. The first cell of a replayed seed is left out, other boards ignore it.
+func (r *room) checkVote(v g.Vote) error {
+	settings := r.s.settings
+	settings.Difficulty, settings.NoGuess, settings.Mode = v.Difficulty, v.NoGuess, v.Mode
+	settings.First = nil
+	return settings.Validate()
+}
+
+func (r *room) String() string {
+
+	ls := []string{"\n"}
+	for _, v := range r.ps {
+		status := "ONLINE"
+		if !v.isOnline {
+			status = "OFFLINE"
+		}
+		ls = append(ls, fmt.Sprintf("%s:%s CURR: %s", v.addr, status, v.cur.String()))
+	}
+	return strings.Join(ls, "\n")
+}
+
+// info describes the room for the rooms list, the caller holds the lock
+func (r *room) info() g.RoomInfo {
+	return g.RoomInfo{
+		Code:       r.code,
+		Board:      r.game.Title(),
+		Players:    len(r.ps),
+		MaxPlayers: r.s.opts.Players,
+		Spectators: len(r.specs),
+		State:      r.game.M.State,
+	}
+}
+
+// roomView is a copy of the room for the server UI, the UI draws it without
+// the room lock
+type roomView struct {
+	info        g.RoomInfo
+	seed        string            // seed code of the board
+	game        *g.Model          // copy of the match
+	players     map[string]player // copies of players by ID
+	currentTurn string
+}
+
+// snapshot copies the room for the server UI
+func (r *room) snapshot() roomView {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	players := make(map[string]player, len(r.ps))
+	for _, p := range r.ps {
+		players[p.id] = *p
+	}
+	return roomView{
+		info:        r.info(),
+		seed:        r.game.SeedCode(),
+		game:        r.game.M.Copy(),
+		players:     players,
+		currentTurn: r.currentTurn,
+	}
+}
+
+// hasPlayer reports whether the session token belongs to a player of the
+// room, a closed room has no players
+func (r *room) hasPlayer(token string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.ps[token]
+	return ok && !r.closed
+}
+
+// isClosed reports whether the room is closed, it's removed from the server soon
+func (r *room) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+// isOpen reports whether a new player can join the game
+func (r *room) isOpen() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.closed && r.game.M.State == g.LOBBY && len(r.ps) < r.s.opts.Players
+}
+
+// close closes the room if nobody is in it or the game is over for a long
+// time already, connections of clients left are closed. Reports whether the
+// room was closed.
+func (r *room) close() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.game.M.IsOver() && r.finished.IsZero() {
+		r.finished = time.Now()
+	}
+	expired := !r.finished.IsZero() && time.Since(r.finished) > finishedRoomTTL
+
+	// give the creator of the room time to join
+	empty := time.Since(r.created) > cleanupInterval
+	for _, ps := range []players{r.ps, r.specs} {
+		for _, p := range ps {
+			if p.isOnline || p.grace != nil {
+				empty = false
+			}
+		}
+	}
+	if !empty && !expired {
+		return false
+	}
+
+	r.closed = true
+	for _, ps := range []players{r.ps, r.specs} {
+		for _, p := range ps {
+			if p.isOnline {
+				_ = p.conn.Close()
+			}
+		}
+	}
+	return true
+}
+
+// disconnectClient marks the player offline, unless the player has already
+// reconnected with another connection. The game in progress is paused for
+// the grace period or forfeited by the player.
+func (r *room) disconnectClient(token string, conn net.Conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.specs[token]; ok {
+		log.Printf("Spectator %s left", p.id)
+		delete(r.specs, token)
+		if p.feed != nil {
+			close(p.feed)
+		}
+		r.s.refresh(noop{})
+		return
+	}
+
+	// the player may be gone already, e.g. dropped before the rematch
+	p, ok := r.ps[token]
+	if !ok || p.conn != conn {
+		return
+	}
+	r.ps.disconnect(token)
+
+	// the game isn't started yet, the slot is free for others
+	if r.game.M.State == g.LOBBY {
+		r.leave(p)
+		r.s.refresh(*p)
+		return
+	}
+
+	// the rematch may wait only for the player
+	if r.game.M.IsOver() {
+		r.updateRematch()
+		r.s.refresh(*p)
+		return
+	}
+
+	// Check if game was in progress and a player disconnected
+	if (r.game.M.State == g.GAME || r.game.M.State == g.PAUSE) && !r.game.M.IsEliminated(p.id) {
+		if r.s.opts.Grace > 0 {
+			r.pause(p)
+		} else {
+			r.forfeit(p)
+		}
+	}
+
+	r.s.refresh(*p)
+}
+
+// pause pauses the game until the player reconnects. If the grace period
+// expires the player forfeits the game.
+func (r *room) pause(p *player) {
+	p.graceUntil = time.Now().Add(r.s.opts.Grace)
+	p.grace = time.AfterFunc(r.s.opts.Grace, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if p.isOnline || r.game.M.IsOver() {
+			return
+		}
+		r.forfeit(p)
+		r.s.refresh(noop{})
+	})
+
+	r.stopTurn()
+	r.game.M.State = g.PAUSE
+	r.updatePause()
+	log.Printf("Game paused: %s", r.game.M.Paused)
+	r.broadcast(nil)
+}
+
+// resume resumes the paused game if all disconnected players are back.
+// Reports whether the game was resumed.
+func (r *room) resume() bool {
+	if r.game.M.State != g.PAUSE {
+		return false
+	}
+	r.updatePause()
+	if r.game.M.Paused != "" {
+		log.Printf("Game still paused: %s", r.game.M.Paused)
+		return false
+	}
+
+	r.game.M.State = g.GAME
+	r.startTurn()
+	log.Print("Game resumed")
+	r.broadcast(nil)
+	return true
+}
+
+// updatePause describes players the paused game waits for
+func (r *room) updatePause() {
+	var waiting []string
+	var until time.Time
+	for _, p := range r.ps {
+		if p.isOnline || p.grace == nil {
+			continue
+		}
+		waiting = append(waiting, p.id)
+		if until.IsZero() || p.graceUntil.Before(until) {
+			until = p.graceUntil
+		}
+	}
+
+	r.game.M.Paused = ""
+	r.game.M.PausedUntil = until
+	if len(waiting) > 0 {
+		sort.Strings(waiting)
+		r.game.M.Paused = strings.Join(waiting, ", ") + " reconnecting"
+	}
+}
+
+// forfeit puts the player out of the game, the last player left wins
+func (r *room) forfeit(p *player) {
+	if p.grace != nil {
+		p.grace.Stop()
+		p.grace = nil
+	}
+
+	r.game.Eliminate(p.id)
+	if r.game.M.IsOver() {
+		log.Printf("Game ended: Player %s disconnected", p.id)
+		r.endRound()
+		r.broadcast(nil)
+		return
+	}
+
+	log.Printf("Player %s disconnected and is out of the game", p.id)
+	if r.currentTurn == p.id {
+		r.switchTurn()
+	}
+	if !r.resume() {
+		r.broadcast(r.game.Delta(nil, nil))
+	}
+}
+
+// connectClient joins the client to the room. A player with a known session
+// token reclaims the slot unless the slot is still online, others get a new
+// slot and token. Clients asking to spectate and everybody beyond the players
+// limit join as spectators. The client is online only after welcome. Returns
+// the session token, empty if the room is closed already, or an error message
+// for the client.
+func (r *room) connectClient(conn net.Conn, addr string, codec g.Codec, hello *g.Hello) (string, *g.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return "", nil
+	}
+	caps := g.CommonCapabilities(hello.Capabilities)
+
+	// Check if this is a reconnection
+	if p, ok := r.ps[hello.Token]; ok {
+		log.Printf("[%s] Player %s reconnected from %s", r.code, p.id, addr)
+		// another client with the same token, e.g. on the same machine
+		if p.isOnline {
+			log.Printf("[%s] Player %s is online, rejecting the same session from %s", r.code, p.id, addr)
+			return "", g.NewError(g.ErrSessionInUse, "player %s is online with this session", p.id)
+		}
+		if p.grace != nil {
+			p.grace.Stop()
+			p.grace = nil
+		}
+		p.conn = conn
+		p.addr = addr
+		p.caps = caps
+		p.codec = codec
+		return p.token, nil
+	}
+
+	token := newToken()
+
+	// Lobby is full or the game has started, slots of offline players are kept
+	// for them
+	if hello.Spectate || len(r.ps) >= r.s.opts.Players || r.game.M.State != g.LOBBY {
+		r.watchers++
+		sp := &player{
+			id:        fmt.Sprintf("S%d", r.watchers),
+			token:     token,
+			conn:      conn,
+			addr:      addr,
+			caps:      caps,
+			codec:     codec,
+			spectator: true,
+		}
+		if r.s.opts.SpectatorDelay > 0 {
+			sp.feed = make(chan delayed, 1024)
+		}
+		r.specs[token] = sp
+		log.Printf("[%s] Spectator %s joined from %s", r.code, sp.id, addr)
+		return token, nil
+	}
+
+	// Add new player
+	r.ps.add(&player{
+		token: token,
+		conn:  conn,
+		addr:  addr,
+		caps:  caps,
+		codec: codec,
+	})
+	id := r.ps[token].id
+	r.game.M.Players = append(r.game.M.Players, id)
+	r.game.M.Votes[id] = r.defaultVote()
+	r.updateLobby()
+	log.Printf("[%s] Player %s joined from %s", r.code, id, addr)
+	return token, nil
+}
+
+func (r *room) updateCursor(token string, p g.Point) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pl := r.ps[token]
+	if pl == nil {
+		return
+	}
+	if !r.game.M.Inside(p) {
+		log.Printf("Cursor %s is out of the field", p.String())
+		return
+	}
+	pl.cur = p
+	r.game.M.Cursors[pl.id] = p
+	r.broadcast(r.game.Delta(nil, nil))
+	r.s.refresh(*pl)
+}
+
+// ping highlights the cell for everybody in the color of the player. Pings
+// more often than pingCooldown are ignored.
+func (r *room) ping(token string, p g.Point) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.game.M
+	if m.State == g.LOBBY || !m.Inside(p) {
+		return
+	}
+
+	pl := r.ps[token]
+	if pl == nil {
+		return
+	}
+	now := time.Now()
+	if now.Sub(pl.pinged) < pingCooldown {
+		log.Printf("[%s] Ping of %s ignored, too often", r.code, pl.id)
+		return
+	}
+	pl.pinged = now
+
+	if m.Pings == nil {
+		m.Pings = make(map[string]g.Ping)
+	}
+	m.Pings[pl.id] = g.Ping{P: p, Until: now.Add(g.PingTime)}
+	r.broadcast(r.game.Delta(nil, nil))
+	r.s.refresh(noop{})
+}
+
+// broadcast sends the delta to all online players and spectators. Clients
+// without delta capability, everybody in the lobby, in RACE and after the
+// game is over get the full game.
+func (r *room) broadcast(d *g.Delta) {
+	r.seq++
+	state := g.NewStateMessage(r.seq, r.game.View())
+	var delta *g.Message
+	if d != nil && !r.game.M.IsOver() && r.game.M.State != g.LOBBY && r.boards == nil {
+		d.Seq = r.seq
+		delta = g.NewDeltaMessage(d)
+	}
+
+	for _, ps := range []players{r.ps, r.specs} {
+		for token, p := range ps {
+			if !p.isOnline {
+				continue
+			}
+			m := state
+			if delta != nil && g.HasCapability(p.caps, g.CapDelta) {
+				m = delta
+			}
+			// racers play private boards
+			if _, ok := r.boards[p.id]; ok && !p.spectator {
+				m = g.NewStateMessage(r.seq, r.view(p))
+			}
+			if err := r.deliver(p, m); err != nil {
+				log.Printf("Error sending data: %s", err.Error())
+				log.Printf("Client %s disconnected", p.addr)
+				ps.disconnect(token)
+			}
+			log.Printf("Game update #%d sent to %s", r.seq, p.id)
+		}
+	}
+}
+
+// deliver sends the message to the client. Messages to spectators are queued
+// for the spectator delay, they are encoded at once to keep the game snapshot.
+// COOP isn't competitive, its messages are queued without the delay to keep
+// them in order.
+func (r *room) deliver(p *player, m *g.Message) error {
+	if p.feed == nil {
+		return p.send(m)
+	}
+	at := time.Now()
+	if r.game.M.Mode != g.COOP {
+		at = at.Add(r.s.opts.SpectatorDelay)
+	}
+	select {
+	case p.feed <- delayed{at: at, data: p.codec.Encode(m)}:
+	default:
+		log.Printf("Spectator %s feed is full, update dropped", p.id)
+	}
+	return nil
+}
+
+// client returns the player or the spectator with the session token, nil if
+// the client has left the room
+func (r *room) client(token string) *player {
+	if p, ok := r.ps[token]; ok {
+		return p
+	}
+	return r.specs[token]
+}
+
+// welcome sends the player ID, session token and the full game to the joined
+// client and puts it online. The game paused for the player is resumed.
+func (r *room) welcome(token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := r.client(token)
+	if p == nil {
+		return fmt.Errorf("client left the room")
+	}
+	if err := p.send(g.NewWelcome(r.code, p.id, token, p.caps, p.spectator)); err != nil {
+		return err
+	}
+	p.isOnline = true
+	if p.feed != nil {
+		go p.watch()
+	}
+	if err := r.deliver(p, g.NewStateMessage(r.seq, r.view(p))); err != nil {
+		return err
+	}
+	if len(r.chat) > 0 {
+		if err := r.deliver(p, g.NewChatMessage(r.chat...)); err != nil {
+			return err
+		}
+	}
+	r.s.refresh(*p)
+
+	// let others know the player is back
+	if !r.resume() && !p.spectator {
+		r.broadcast(r.game.Delta(nil, nil))
+	}
+	return nil
+}
+
+// sendGame sends the full game to the client on resync request
+func (r *room) sendGame(token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := r.client(token)
+	if p == nil {
+		return nil
+	}
+	return r.deliver(p, g.NewStateMessage(r.seq, r.view(p)))
+}
+
+// view returns the game as the client sees it. In RACE players see their
+// private boards with the race around them, spectators see the opening.
+func (r *room) view(p *player) *g.Game {
+	board, ok := r.boards[p.id]
+	if !ok || p.spectator {
+		return r.game.View()
+	}
+
+	match := r.game.M
+	m := *board.RaceView(match.IsOver()).M
+	m.State = match.State
+	m.Winner = match.Winner
+	m.Paused = match.Paused
+	m.PausedUntil = match.PausedUntil
+	m.Players = match.Players
+	m.Eliminated = match.Eliminated
+	m.Series = match.Series
+	m.Progress = match.Progress
+	m.Cursors = map[string]g.Point{p.id: p.cur}
+	m.Pings = match.Pings
+
+	// the seed gives away boards of others
+	var seed int64
+	var first g.Point
+	if match.IsOver() {
+		seed, first = board.Seed, board.First
+	}
+	return &g.Game{M: &m, Difficulty: board.Difficulty, Seed: seed, First: first}
+}
+
+// board returns the board the player plays, the private one in RACE
+func (r *room) board(p *player) *g.Game {
+	if b, ok := r.boards[p.id]; ok {
+		return b
+	}
+	return r.game
+}
+
+func (r *room) playerID(token string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c := r.client(token); c != nil {
+		return c.id
+	}
+	return ""
+}
+
+func (r *room) isSpectator(token string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c := r.client(token); c != nil {
+		return c.spectator
+	}
+	return false
+}
+
+// switchTurn passes the turn to the next player still in the game
+func (r *room) switchTurn() {
+	r.stopTurn()
+	r.currentTurn = r.game.M.NextPlayer(r.currentTurn)
+	r.game.M.CurrentTurn = r.currentTurn
+	r.startTurn()
+	log.Printf("Turn switched to %s", r.currentTurn)
+}
+
+// isPlayerTurn reports whether the player may move now, in COOP everybody
+// still in the game may
+func (r *room) isPlayerTurn(token string) bool {
+	player := r.ps[token]
+	if player == nil {
+		return false
+	}
+	if !r.game.M.Mode.HasTurns() {
+		return !r.game.M.IsEliminated(player.id)
+	}
+	return player.id == r.currentTurn
+}
+
+// openCell opens the cell under the cursor of the player at the time of the
+// event, the cursor may move while the board is laid out for the first move
+func (r *room) openCell(token string) {
+	cell, laidOut := r.layOutFirst(token)
+	r.move(token, func(cur g.Point) []g.Cell {
+		if laidOut {
+			cur = cell
+		}
+		board := r.board(r.ps[token])
+		// don't open cells flagged by the player
+		if board.M.Mark(r.ps[token].id, cur) == g.FLAG {
+			return nil
+		}
+		return board.OpenCell(cur, r.ps[token].id)
+	})
+}
+
+func (r *room) chord(token string) {
+	r.move(token, func(cur g.Point) []g.Cell {
+		return r.board(r.ps[token]).Chord(cur, r.ps[token].id)
+	})
+}
+
+// toggleMark sets or removes player's marker. Markers are personal, so
+// players can place them at any time, not only during their turn.
+func (r *room) toggleMark(token string, t g.EventType) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p := r.ps[token]
+	if p == nil || r.laying {
+		return
+	}
+	var markers []g.Marker
+	switch t {
+	case g.ToggleFlag:
+		markers = r.board(p).ToggleFlag(p.id, p.cur)
+	case g.ToggleGuess:
+		markers = r.board(p).ToggleGuess(p.id, p.cur)
+	}
+	if len(markers) == 0 {
+		return
+	}
+
+	r.broadcast(r.game.Delta(nil, markers))
+	r.s.refresh(noop{})
+}
+
+// move makes a turn of the player with the token at the player cursor. The turn
+// doesn't pass if makeMove changed no cells.
+func (r *room) move(token string, makeMove func(cur g.Point) []g.Cell) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ps[token] == nil || r.laying {
+		return
+	}
+	if r.game.M.State != g.GAME {
+		log.Printf("Player %s tried to move, but the game is not in progress", r.ps[token].id)
+		return
+	}
+
+	// Check if it's this player's turn
+	if !r.isPlayerTurn(token) {
+		playerID := r.ps[token].id
+		log.Printf("Player %s tried to move, but it's %s's turn", playerID, r.currentTurn)
+		return
+	}
+
+	currentPlayer := r.ps[token].id
+	found := r.game.M.Score[currentPlayer]
+
+	changed := makeMove(r.ps[token].cur)
+	if len(changed) == 0 {
+		return
+	}
+
+	if board, ok := r.boards[currentPlayer]; ok {
+		log.Printf("Updated %s: %s", currentPlayer, board)
+		r.race(currentPlayer, board)
+		r.broadcast(nil)
+		r.s.refresh(noop{})
+		return
+	}
+	log.Printf("Updated: %s", r.game)
+
+	// Check if game ended, the winner is set by the game
+	if r.game.M.Mode == g.FLAGS {
+		r.flags(currentPlayer, found)
+	} else if r.game.M.State == g.WIN {
+		// Current player wins by opening the last safe cell
+		log.Printf("Player %s wins by completing the field!", currentPlayer)
+		r.endRound()
+	} else if r.game.M.State == g.OVER {
+		// Current player loses by hitting a mine, the last player left wins
+		log.Printf("Player %s hit a mine! Player %s wins!", currentPlayer, r.game.M.Winner)
+		r.endRound()
+	} else if r.game.M.State == g.GAME {
+		if r.game.M.IsEliminated(currentPlayer) {
+			log.Printf("Player %s hit a mine and is out of the game", currentPlayer)
+		}
+		// Switch turn only if game continues
+		if r.game.M.Mode.HasTurns() {
+			r.switchTurn()
+		}
+	}
+
+	r.broadcast(r.game.Delta(changed, nil))
+	r.s.refresh(noop{})
+}
+
+// flags passes the turn in FLAGS unless the player has found a mine, found
+// is the player's score before the move
+func (r *room) flags(id string, found int) {
+	m := r.game.M
+	switch {
+	case m.IsOver() && m.Winner == "":
+		log.Print("All mines are found, it's a draw")
+		r.endRound()
+	case m.IsOver():
+		log.Printf("Player %s wins with %d mines found!", m.Winner, m.Score[m.Winner])
+		r.endRound()
+	case m.Score[id] > found:
+		log.Printf("Player %s found a mine and goes again", id)
+		r.stopTurn()
+		r.startTurn()
+	default:
+		r.switchTurn()
+	}
+}
+
+// race updates the race after the player's move on the private board: the
+// first to clear the board wins, a mine puts the player out of the race
+func (r *room) race(id string, board *g.Game) {
+	m := r.game.M
+	m.Progress[id] = board.M.LeftToOpen
+
+	switch board.M.State {
+	case g.WIN:
+		m.State = g.WIN
+		m.Winner = id
+		log.Printf("Player %s wins the race!", id)
+		r.endRound()
+	case g.OVER:
+		r.game.Eliminate(id)
+		if !m.IsOver() {
+			log.Printf("Player %s hit a mine and is out of the race", id)
+			return
+		}
+		log.Printf("Player %s hit a mine! Player %s wins the race!", id, m.Winner)
+		r.endRound()
+	}
+}
+
+// handleEvent handles game events of the joined player, spectators may only
+// ask for a resync:
+// ✓ CursorMove - updates player cursor position
+// ✓ OpenCell - opens cell and updates all clients (with turn validation)
+// ✓ Chord - opens neighbours of a satisfied number (with turn validation)
+// ✓ Turn-based gameplay (P1 -> P2 -> ...)
+// ✓ Resync - sends the full game when a client missed a delta
+// ✓ ToggleFlag/ToggleGuess - per-player FLAG and GESS markers, shared with all clients
+// ✓ ToggleReady/CastVote - ready check and the board vote in the lobby
+// ✓ Rematch - asks for the next game of the series after the game is over
+// ✓ Chat - relays the message to everybody in the room
+// ✓ PingCell - highlights the cell for everybody for a few seconds
+func (r *room) handleEvent(token string, e *g.Event) {
+	if r.playerID(token) == "" {
+		log.Printf("[%s] Event of a client who left the room ignored: %s", r.code, e)
+		return
+	}
+	log.Printf("[%s] %s", r.playerID(token), e)
+
+	if r.isSpectator(token) && e.Type != g.Resync {
+		log.Printf("[%s] Spectator event ignored", r.playerID(token))
+		return
+	}
+
+	switch e.Type {
+	case g.NoOp:
+		r.s.refresh(noop{})
+	case g.CursorMove:
+		r.updateCursor(token, e.Position)
+	case g.OpenCell:
+		r.openCell(token)
+	case g.Chord:
+		r.chord(token)
+	case g.ToggleFlag, g.ToggleGuess:
+		r.toggleMark(token, e.Type)
+	case g.ToggleReady:
+		r.toggleReady(token)
+	case g.CastVote:
+		r.vote(token, e.Vote)
+	case g.Rematch:
+		r.rematch(token)
+	case g.Chat:
+		r.say(token, e.Text)
+	case g.PingCell:
+		r.ping(token, e.Position)
+	case g.Resync:
+		if err := r.sendGame(token); err != nil {
+			log.Printf("Error sending data: %s", err.Error())
+		}
+	default:
+		log.Printf("not implemented yet: %s", e.String())
+	}
+}
+
+// say relays the chat message of the player to all online clients and keeps
+// it in the history. Control characters are dropped, empty messages ignored.
+func (r *room) say(token, text string) {
+	text = strings.TrimSpace(strings.Map(func(c rune) rune {
+		if unicode.IsControl(c) {
+			return -1
+		}
+		return c
+	}, text))
+	if text == "" {
+		return
+	}
+	if rs := []rune(text); len(rs) > chatMaxLen {
+		text = string(rs[:chatMaxLen])
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ps[token] == nil {
+		return
+	}
+
+	msg := g.ChatMessage{From: r.ps[token].id, Text: text, At: time.Now()}
+	r.chat = append(r.chat, msg)
+	if len(r.chat) > chatHistory {
+		r.chat = r.chat[len(r.chat)-chatHistory:]
+	}
+	log.Printf("[%s] %s says: %s", r.code, msg.From, msg.Text)
+
+	m := g.NewChatMessage(msg)
+	for _, ps := range []players{r.ps, r.specs} {
+		for token, p := range ps {
+			if !p.isOnline {
+				continue
+			}
+			if err := r.deliver(p, m); err != nil {
+				log.Printf("Error sending data: %s", err.Error())
+				log.Printf("Client %s disconnected", p.addr)
+				ps.disconnect(token)
+			}
+		}
+	}
+	r.s.refresh(noop{})
+}
+
+// leave removes the player from the lobby
+func (r *room) leave(p *player) {
+	delete(r.ps, p.token)
+	m := r.game.M
+	for i, id := range m.Players {
+		if id == p.id {
+			m.Players = append(m.Players[:i:i], m.Players[i+1:]...)
+			break
+		}
+	}
+	delete(m.Ready, p.id)
+	delete(m.Votes, p.id)
+	delete(m.Cursors, p.id)
+	log.Printf("[%s] Player %s left the lobby", r.code, p.id)
+
+	r.updateLobby()
+	r.broadcast(nil)
+}
+
+func (r *room) toggleReady(token string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.game.M.State != g.LOBBY || r.ps[token] == nil {
+		return
+	}
+
+	id := r.ps[token].id
+	r.game.M.Ready[id] = !r.game.M.Ready[id]
+	r.updateLobby()
+	r.broadcast(nil)
+	r.s.refresh(noop{})
+}
+
+// vote changes the board the player votes for. CUSTOM board can be chosen
+// only if the server is started with it, its size comes from the settings.
+// Boards too dense for no-guess can't be chosen with it.
+func (r *room) vote(token string, v *g.Vote) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.game.M.State != g.LOBBY || v == nil || r.ps[token] == nil {
+		return
+	}
+	if v.Difficulty < g.EASY || v.Difficulty > g.CUSTOM || v.Mode < g.VERSUS || int(v.Mode) >= len(g.Modes()) ||
+		(v.Difficulty == g.CUSTOM && r.s.settings.Difficulty != g.CUSTOM) {
+		log.Printf("[%s] Bad vote of %s: %s", r.code, r.ps[token].id, v)
+		return
+	}
+	if err := r.checkVote(*v); err != nil {
+		log.Printf("[%s] Bad vote of %s: %s", r.code, r.ps[token].id, err)
+		return
+	}
+
+	r.game.M.Votes[r.ps[token].id] = *v
+	r.updateLobby()
+	r.broadcast(nil)
+	r.s.refresh(noop{})
+}
+
+// minPlayers is how many players are needed to start a game
+func (r *room) minPlayers() int {
+	if r.s.opts.Players < 2 {
+		return r.s.opts.Players
+	}
+	return 2
+}
+
+// updateLobby counts votes and starts the countdown once enough players are
+// ready, any change of players stops it
+func (r *room) updateLobby() {
+	m := r.game.M
+	m.Choice = g.Tally(m.Votes, r.defaultVote())
+	// votes for no-guess and for a dense board may add up to a choice nobody
+	// could vote for
+	if err := r.checkVote(m.Choice); err != nil {
+		log.Printf("[%s] The chosen board is played with guessing: %s", r.code, err)
+		m.Choice.NoGuess = false
+	}
+
+	if len(m.Players) >= r.minPlayers() && m.IsReady() {
+		if r.countdown == nil {
+			m.StartsAt = time.Now().Add(lobbyCountdown)
+			r.countdowns++
+			n := r.countdowns
+			r.countdown = time.AfterFunc(lobbyCountdown, func() { r.start(n) })
+			log.Printf("[%s] Everybody is ready, the game starts in %s", r.code, lobbyCountdown)
+		}
+		return
+	}
+
+	if r.countdown != nil {
+		r.countdown.Stop()
+		r.countdown = nil
+		m.StartsAt = time.Time{}
+		log.Printf("[%s] Countdown stopped", r.code)
+	}
+}
+
+// start starts the game on the board chosen in the lobby, it's the first
+// game of a new series. The countdown may fire while it's being stopped, so
+// the start of a stopped or restarted countdown n is ignored.
+func (r *room) start(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.game.M.State != g.LOBBY || r.countdown == nil || n != r.countdowns {
+		return
+	}
+	r.countdown = nil
+
+	players := r.game.M.Players
+	r.series = g.NewSeries(r.s.opts.BestOf)
+
+	// P1 starts
+	r.newRound(players, players[0], r.s.settings)
+	log.Printf("[%s] Game started: %s", r.code, r.game.Title())
+}
+
+// newRound starts a game of the players on the board chosen in the lobby, the
+// first player moves first, in COOP and RACE nobody takes turns. Cursors are kept if
+// they fit the new board.
+func (r *room) newRound(players []string, first string, settings g.Settings) {
+	choice := r.game.M.Choice
+	settings.Difficulty = choice.Difficulty
+	settings.NoGuess = choice.NoGuess
+	settings.Mode = choice.Mode
+
+	r.game = g.NewGame(settings, true)
+	m := r.game.M
+	m.Players = players
+	m.Choice = choice
+	m.Series = r.series
+	if m.Mode != g.COOP {
+		for _, id := range players {
+			// show everybody on the scoreboard
+			r.series.Score[id] += 0
+		}
+	}
+	if !m.Mode.HasTurns() {
+		first = ""
+	}
+	for _, p := range r.ps {
+		if !m.Inside(p.cur) {
+			p.cur = g.Point{}
+		}
+		m.Cursors[p.id] = p.cur
+	}
+
+	r.currentTurn = first
+	m.CurrentTurn = first
+	r.finished = time.Time{}
+
+	if r.s.opts.Clock > 0 {
+		m.Clocks = make(map[string]time.Duration)
+		for _, id := range players {
+			m.Clocks[id] = r.s.opts.Clock
+		}
+	}
+	r.boards = nil
+	cell, ok := r.game.Opening()
+	r.laying = ok
+	if ok {
+		go r.layOut(r.game, cell)
+	} else {
+		r.startTurn()
+	}
+
+	r.broadcast(nil)
+	r.s.refresh(noop{})
+}
+
+// layOut lays the board of the round out around its opening. The search for a
+// no-guess board is long, so it runs without the lock, moves wait for it.
+// Racers get their copies of the board then.
+func (r *room) layOut(game *g.Game, cell g.Point) {
+	layout := game.Layout(cell)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.game != game {
+		return
+	}
+	r.laying = false
+	if game.M.IsOver() {
+		return
+	}
+	game.Place(layout)
+	r.startTurn()
+
+	// racers get copies of the same board
+	m := game.M
+	if m.Mode == g.RACE {
+		r.boards = game.RaceBoards(m.Players)
+		m.Progress = make(map[string]int)
+		for id, b := range r.boards {
+			m.Progress[id] = b.M.LeftToOpen
+		}
+		if m.IsOver() {
+			r.endRound()
+		}
+	}
+
+	r.broadcast(nil)
+	r.s.refresh(noop{})
+}
+
+// layOutFirst lays the board out for the cell under the cursor of the player
+// making the first move, without holding the lock like layOut. Reports the
+// cell if the board was laid out for it.
+func (r *room) layOutFirst(token string) (g.Point, bool) {
+	r.mu.Lock()
+	p := r.ps[token]
+	if p == nil || r.laying || r.game.M.State != g.GAME || !r.isPlayerTurn(token) {
+		r.mu.Unlock()
+		return g.Point{}, false
+	}
+	board, cell := r.board(p), p.cur
+	if board.M.Placed || board.M.Mark(p.id, cell) == g.FLAG {
+		r.mu.Unlock()
+		return g.Point{}, false
+	}
+	r.mu.Unlock()
+
+	layout := board.Layout(cell)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	board.Place(layout)
+	return cell, true
+}
+
+// endRound records the result of the finished game in the series
+func (r *room) endRound() {
+	r.stopTurn()
+	r.series.Record(r.game.M.Winner)
+	log.Printf("[%s] %s", r.code, r.series)
+	if r.series.Winner != "" {
+		log.Printf("[%s] Player %s wins the series", r.code, r.series.Winner)
+	}
+	if r.series.Draw {
+		log.Printf("[%s] The series is a draw", r.code)
+	}
+}
+
+// rematch toggles the player's request for the next game
+func (r *room) rematch(token string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.game.M.IsOver() || r.ps[token] == nil {
+		return
+	}
+
+	id := r.ps[token].id
+	r.series.Rematch[id] = !r.series.Rematch[id]
+	if r.series.Rematch[id] {
+		log.Printf("[%s] Player %s asks for a rematch", r.code, id)
+	}
+	if !r.updateRematch() {
+		r.broadcast(nil)
+		r.s.refresh(noop{})
+	}
+}
+
+// updateRematch starts the next game once every online player asked for it.
+// Players who left aren't waited for, they lose their slots. The loser of the
+// last game moves first. Reports whether the game was started.
+func (r *room) updateRematch() bool {
+	var players []string
+	for _, id := range r.game.M.Players {
+		p := r.ps.getByID(id)
+		if p == nil {
+			continue
+		}
+		if p.isOnline && !r.series.Rematch[id] {
+			return false
+		}
+		if r.series.Rematch[id] {
+			players = append(players, id)
+		}
+	}
+	if len(players) == 0 || len(players) < r.minPlayers() {
+		return false
+	}
+
+	for token, p := range r.ps {
+		if !r.series.Rematch[p.id] {
+			log.Printf("[%s] Player %s left before the rematch", r.code, p.id)
+			if p.conn != nil {
+				_ = p.conn.Close()
+			}
+			delete(r.ps, token)
+		}
+	}
+
+	first := r.game.M.Loser()
+	if !r.series.Rematch[first] {
+		first = players[0]
+	}
+	r.series.Next()
+
+	// a rematch is played on a new board, even if the server replays a seed
+	settings := r.s.settings
+	settings.Seed = 0
+	settings.First = nil
+	r.newRound(players, first, settings)
+	log.Printf("[%s] Rematch started: %s", r.code, r.game.Title())
+	if r.currentTurn != "" {
+		log.Printf("[%s] %s moves first", r.code, r.currentTurn)
+	}
+	return true
+}
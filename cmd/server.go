@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/muesli/termenv"
@@ -17,7 +22,16 @@ import (
 )
 
 const (
-	MAX_PLAYERS = 2
+	MAX_PLAYERS = 8 // one color of playerStyles for each
+
+	// cleanupInterval is how often empty and finished rooms are removed
+	cleanupInterval = 30 * time.Second
+
+	// pingInterval is how often the server pings clients. A connection silent
+	// for idleTimeout is dropped, e.g. after a network failure, which doesn't
+	// close it.
+	pingInterval = 10 * time.Second
+	idleTimeout  = 3 * pingInterval
 )
 
 var (
@@ -28,18 +42,76 @@ var (
 	P1Style = termenv.Style{}.Foreground(Color("11")).Styled
 	P2Style = termenv.Style{}.Foreground(Color("13")).Styled
 
+	playerStyles = []func(string) string{
+		P1Style,
+		P2Style,
+		termenv.Style{}.Foreground(Color("14")).Styled,
+		termenv.Style{}.Foreground(Color("10")).Styled,
+		termenv.Style{}.Foreground(Color("12")).Styled,
+		termenv.Style{}.Foreground(Color("208")).Styled,
+		termenv.Style{}.Foreground(Color("141")).Styled,
+		termenv.Style{}.Foreground(Color("15")).Styled,
+	}
+
+	// backgrounds of cells pinged by players, in colors of playerStyles
+	pingStyles = []func(string) string{
+		termenv.Style{}.Foreground(Color("0")).Background(Color("11")).Styled,
+		termenv.Style{}.Foreground(Color("0")).Background(Color("13")).Styled,
+		termenv.Style{}.Foreground(Color("0")).Background(Color("14")).Styled,
+		termenv.Style{}.Foreground(Color("0")).Background(Color("10")).Styled,
+		termenv.Style{}.Foreground(Color("0")).Background(Color("12")).Styled,
+		termenv.Style{}.Foreground(Color("0")).Background(Color("208")).Styled,
+		termenv.Style{}.Foreground(Color("0")).Background(Color("141")).Styled,
+		termenv.Style{}.Foreground(Color("0")).Background(Color("15")).Styled,
+	}
+
 	// 	mainStyle       = termenv.Style{}.Foreground(color("11")).Styled
 	// 	modelFieldStyle = termenv.Style{}.Foreground(color("39")).Styled
 	// 	modelValStyle   = termenv.Style{}.Foreground(color("87")).Styled
 )
 
+// playerStyle returns the style of player's ID, cursor and markers
+func playerStyle(id string) func(string) string {
+	var n int
+	if _, err := fmt.Sscanf(id, "P%d", &n); err == nil && n >= 1 && n <= len(playerStyles) {
+		return playerStyles[n-1]
+	}
+	return func(s string) string { return s }
+}
+
+// pingStyle returns the style of the cell pinged by the player
+func pingStyle(id string) func(string) string {
+	var n int
+	if _, err := fmt.Sscanf(id, "P%d", &n); err == nil && n >= 1 && n <= len(pingStyles) {
+		return pingStyles[n-1]
+	}
+	return termenv.Style{}.Reverse().Styled
+}
+
 type player struct {
 	id       string
+	token    string // session token, players reclaim their slot with it on reconnect
 	addr     string
 	conn     net.Conn
+	caps     []string // protocol capabilities negotiated on join
+	codec    g.Codec  // messages encoding chosen by the client on join
 	isOnline bool
 
 	cur g.Point
+
+	grace      *time.Timer // forfeits the game if the player doesn't reconnect in time
+	graceUntil time.Time
+
+	spectator bool
+	feed      chan delayed // messages to the spectator, sent after the spectator delay
+
+	pinged time.Time // the last ping of the player, for the rate limit
+}
+
+// delayed is an encoded message to send not before the time
+type delayed struct {
+	at   time.Time
+	data []byte
 }
 
 func (p player) String() string {
@@ -51,16 +123,87 @@ func (p player) String() string {
 		status = online
 	}
 
-	var id string
-	if p.id == "P1" {
-		id = P1Style(p.id)
+	id := playerStyle(p.id)(p.id)
+
+	return fmt.Sprintf("%s [%s]: %s => [%d:%d]", id, p.addr, status, p.cur[0], p.cur[1])
+}
+
+// send sends the message to the player connection
+func (p *player) send(m *g.Message) error {
+	return writeMessage(p.conn, p.codec, m)
+}
+
+// watch sends the delayed messages to the spectator until the feed is closed.
+// On a write error the connection is closed, so the spectator leaves the room
+// and isn't fed anymore.
+func (p *player) watch() {
+	for d := range p.feed {
+		time.Sleep(time.Until(d.at))
+		if err := writeFrame(p.conn, p.codec, d.data); err != nil {
+			log.Printf("Error sending data to spectator %s: %s", p.id, err.Error())
+			_ = p.conn.Close()
+			return
+		}
+	}
+}
+
+// writeMessage writes the message as a binary frame for gob and as a text
+// frame for JSON
+func writeMessage(conn net.Conn, codec g.Codec, m *g.Message) error {
+	return writeFrame(conn, codec, codec.Encode(m))
+}
+
+// writeFrame writes the message encoded with the codec. The frame is written
+// at once, so frames written by others (e.g. pings) don't break into it.
+func writeFrame(conn net.Conn, codec g.Codec, data []byte) error {
+	op := ws.OpBinary
+	if codec == g.JSON {
+		op = ws.OpText
 	}
+	var frame bytes.Buffer
+	if err := wsutil.WriteServerMessage(&frame, op, data); err != nil {
+		return err
+	}
+	_, err := conn.Write(frame.Bytes())
+	return err
+}
 
-	if p.id == "P2" {
-		id = P2Style(p.id)
+// aliveConn extends the read deadline on every read, so the connection is
+// dropped only after idleTimeout without any frames, pongs included
+type aliveConn struct {
+	net.Conn
+}
+
+func (c aliveConn) Read(p []byte) (int, error) {
+	if err := c.SetReadDeadline(time.Now().Add(idleTimeout)); err != nil {
+		return 0, err
 	}
+	return c.Conn.Read(p)
+}
 
-	return fmt.Sprintf("%s [%s]: %s => [%d:%d]", id, p.addr, status, p.cur[0], p.cur[1])
+// keepAlive pings the client until done, clients answer with pongs
+func keepAlive(conn net.Conn, done <-chan struct{}) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if _, err := conn.Write(ws.CompiledPing); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// codecOf returns the codec of the frame: JSON in text frames, gob in binary ones
+func codecOf(op ws.OpCode) g.Codec {
+	if op == ws.OpText {
+		return g.JSON
+	}
+	return g.Gob
 }
 
 type players map[string]*player
@@ -75,23 +218,23 @@ func (ps players) getByID(id string) *player {
 }
 
 func (ps players) add(player *player) {
-	if p, ok := ps[player.addr]; ok {
+	if p, ok := ps[player.token]; ok {
 		// reconnect
 		p.isOnline = true
 	} else {
-		// new player
-		n := len(ps) + 1
-		ps[player.addr] = player
-		ps[player.addr].id = fmt.Sprintf("P%d", n)
+		// new player gets the first free ID, players may leave the lobby
+		n := 1
+		for ps.getByID(fmt.Sprintf("P%d", n)) != nil {
+			n++
+		}
+		ps[player.token] = player
+		ps[player.token].id = fmt.Sprintf("P%d", n)
 	}
 }
 
-func (ps players) disconnect(addr string) {
-	for k, v := range ps {
-		if v.addr == addr {
-			ps[k].isOnline = false
-			return
-		}
+func (ps players) disconnect(token string) {
+	if p, ok := ps[token]; ok {
+		p.isOnline = false
 	}
 }
 
@@ -105,28 +248,54 @@ func (ps players) countOnline() int {
 	return count
 }
 
+// ServerOpts configures the network side of the server
+type ServerOpts struct {
+	Addr       string // address to bind
+	TLSCert    string // TLS certificate file, the server runs wss:// if set
+	TLSKey     string // TLS key file
+	SelfSigned bool   // generate a self-signed certificate if there is none yet
+	Players    int    // players of the game, up to MAX_PLAYERS
+
+	// Grace is how long the game waits for a disconnected player, the player
+	// forfeits the game after. Zero ends the game at once.
+	Grace time.Duration
+	// SpectatorDelay delays updates sent to spectators, so they can't prompt
+	// players in competitive games
+	SpectatorDelay time.Duration
+	// BestOf is the number of games in a series of rematches, 0 for an endless
+	// series
+	BestOf int
+	// TurnTime limits each move, 0 for no limit. OnTimeout tells what happens
+	// to the move out of time: TimeoutSkip or TimeoutForfeit.
+	TurnTime  time.Duration
+	OnTimeout string
+	// Clock is the chess clock budget of each player for the whole game, the
+	// player is out of the game once it runs out. 0 for no clock.
+	Clock time.Duration
+}
+
 type Srv struct {
-	game        *g.Game
-	ps          players
-	ui          *tea.Program
-	currentTurn string // "P1" or "P2"
+	opts     ServerOpts
+	settings g.Settings       // board settings of new rooms
+	rooms    map[string]*room // rooms by code
+	ui       *tea.Program
+	redraw   chan tea.Msg // messages to the UI, see refresh
 
 	logger g.Logger
 	dbg    bool
 
-	mu sync.Mutex
+	mu sync.Mutex // guards rooms, never held while a room is locked
 }
 
-func NewServer(game *g.Game, logger g.Logger, dbg bool) *Srv {
+func NewServer(settings g.Settings, opts ServerOpts, logger g.Logger, dbg bool) *Srv {
 	s := new(Srv)
-	s.game = game
-	s.ps = make(players)
-	s.currentTurn = "P1" // P1 starts
-	s.game.M.CurrentTurn = "P1" // Initialize in model
+	s.opts = opts
+	s.settings = settings
+	s.rooms = make(map[string]*room)
+	s.redraw = make(chan tea.Msg, 1)
 	s.ui = tea.NewProgram(serverUIModel{
-		Model: game.M,
-		s:     s,
-		dbg:   false,
+		s:   s,
+		dbg: false,
 	})
 
 	s.dbg = dbg
@@ -135,241 +304,326 @@ func NewServer(game *g.Game, logger g.Logger, dbg bool) *Srv {
 	return s
 }
 
-func (s *Srv) String() string {
+// connectClient joins the client to a room after the protocol handshake, see
+// pickRoom. Returns the room and the session token or an error message for
+// the client.
+func (s *Srv) connectClient(conn net.Conn, addr string, codec g.Codec, hello *g.Hello) (*room, string, *g.Message) {
+	if hello.Version != g.ProtocolVersion {
+		log.Printf("Protocol version mismatch, rejecting player from %s: v%d", addr, hello.Version)
+		return nil, "", g.NewError(g.ErrVersion, "server speaks protocol v%d, client v%d", g.ProtocolVersion, hello.Version)
+	}
+
+	for {
+		r, errMsg := s.pickRoom(hello)
+		if errMsg != nil {
+			return nil, "", errMsg
+		}
+		token, errMsg := r.connectClient(conn, addr, codec, hello)
+		if errMsg != nil {
+			return nil, "", errMsg
+		}
+		if token != "" {
+			return r, token, nil
+		}
+		// the room has just been closed, pick another one
+	}
+}
+
+// pickRoom finds the room for the client: a player with a known session token
+// goes back to the room of the token, others join the room by code, create a
+// new room or join the first open room. Closed rooms are skipped, they're
+// being removed.
+func (s *Srv) pickRoom(hello *g.Hello) (*room, *g.Message) {
+	rooms := s.roomList()
+	if hello.Token != "" {
+		for _, r := range rooms {
+			if r.hasPlayer(hello.Token) {
+				return r, nil
+			}
+		}
+	}
+
+	if hello.Room != "" {
+		if r, ok := s.room(strings.ToUpper(hello.Room)); ok && !r.isClosed() {
+			return r, nil
+		}
+		log.Printf("Room %s not found", hello.Room)
+		return nil, g.NewError(g.ErrNoRoom, "room %s not found", hello.Room)
+	}
 
-	ls := []string{"\n"}
-	for _, v := range s.ps {
-		status := "ONLINE"
-		if !v.isOnline {
-			status = "OFFLINE"
+	if !hello.Create {
+		for _, r := range rooms {
+			if r.isOpen() || hello.Spectate && !r.isClosed() {
+				return r, nil
+			}
 		}
-		ls = append(ls, fmt.Sprintf("%s:%s CURR: %s", v.addr, status, v.cur.String()))
 	}
-	return strings.Join(ls, "\n")
+	return s.createRoom(), nil
 }
 
-func (s *Srv) disconnectClient(addr string) {
+// createRoom creates a room with a new game and a unique code
+func (s *Srv) createRoom() *room {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.ps.disconnect(addr)
-	
-	// Check if game was in progress and a player disconnected
-	if s.game.M.State == g.GAME && s.ps.countOnline() < MAX_PLAYERS {
-		// End the game if a player disconnects during active gameplay
-		s.game.M.State = g.OVER
-		log.Printf("Game ended: Player disconnected")
-		s.updateAllClients()
+	code := newRoomCode()
+	for s.rooms[code] != nil {
+		code = newRoomCode()
 	}
-	
-	s.ui.Send(*s.ps[addr])
+	r := newRoom(s, code, g.NewGame(s.settings, true))
+	s.rooms[code] = r
+	s.mu.Unlock()
+
+	log.Printf("Room %s created: %s", code, r.game.Title())
+	s.refresh(noop{})
+	return r
 }
 
-func (s *Srv) connectClient(conn net.Conn, addr string) bool {
+func (s *Srv) room(code string) (*room, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	
-	// Check if this is a reconnection
-	if p, ok := s.ps[addr]; ok {
-		p.isOnline = true
-		s.ui.Send(*s.ps[addr])
-		return true
-	}
-	
-	// Check if lobby is full (only count online players)
-	if s.ps.countOnline() >= MAX_PLAYERS {
-		log.Printf("Lobby full, rejecting player from %s", addr)
-		return false
-	}
-	
-	// Add new player
-	s.ps.add(&player{
-		conn:     conn,
-		addr:     addr,
-		isOnline: true,
-	})
-	s.ui.Send(*s.ps[addr])
-	return true
+	r, ok := s.rooms[code]
+	return r, ok
 }
 
-func (s *Srv) updateCursor(addr string, p g.Point) {
+// refresh asks the UI to redraw. Rooms call it holding their lock, so it
+// never waits for the UI, which takes room locks to draw them. A message is
+// dropped if a redraw is pending already.
+func (s *Srv) refresh(msg tea.Msg) {
+	select {
+	case s.redraw <- msg:
+	default:
+	}
+}
+
+// roomList returns all rooms, the oldest first
+func (s *Srv) roomList() []*room {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.ps[addr].cur = p
-	s.ui.Send(*s.ps[addr])
+
+	rooms := make([]*room, 0, len(s.rooms))
+	for _, r := range s.rooms {
+		rooms = append(rooms, r)
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].created.Before(rooms[j].created)
+	})
+	return rooms
 }
 
-func (s *Srv) updateAllClients() {
-	for addr := range s.ps {
-		if !s.ps[addr].isOnline {
-			continue
+// openRooms describes rooms with games in progress for the rooms list
+func (s *Srv) openRooms() []g.RoomInfo {
+	var list []g.RoomInfo
+	for _, r := range s.roomList() {
+		r.mu.Lock()
+		info := r.info()
+		r.mu.Unlock()
+		if info.State != g.OVER && info.State != g.WIN {
+			list = append(list, info)
 		}
-		if err := wsutil.WriteServerMessage(s.ps[addr].conn, ws.OpBinary, s.game.Bytes()); err != nil {
-			log.Printf("Error sending data: %s", err.Error())
-			log.Printf("Client %s disconnected", addr)
-			s.ps.disconnect(addr)
-		}
-		log.Printf("Game update sent to %s", addr)
 	}
+	return list
 }
 
-func (s *Srv) switchTurn() {
-	if s.currentTurn == "P1" {
-		s.currentTurn = "P2"
-	} else {
-		s.currentTurn = "P1"
+// cleanup removes empty rooms and rooms finished long ago
+func (s *Srv) cleanup() {
+	for range time.Tick(cleanupInterval) {
+		for _, r := range s.roomList() {
+			if !r.close() {
+				continue
+			}
+			s.mu.Lock()
+			delete(s.rooms, r.code)
+			s.mu.Unlock()
+			log.Printf("Room %s removed", r.code)
+		}
+		s.refresh(noop{})
 	}
-	s.game.M.CurrentTurn = s.currentTurn
-	log.Printf("Turn switched to %s", s.currentTurn)
 }
 
-func (s *Srv) isPlayerTurn(addr string) bool {
-	player := s.ps[addr]
-	if player == nil {
-		return false
+// newToken generates a random session token
+func newToken() string {
+	bs := make([]byte, 16)
+	if _, err := rand.Read(bs); err != nil {
+		panic(err)
 	}
-	return player.id == s.currentTurn
+	return hex.EncodeToString(bs)
 }
 
-func (s *Srv) openCell(addr string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	
-	// Check if it's this player's turn
-	if !s.isPlayerTurn(addr) {
-		playerID := s.ps[addr].id
-		log.Printf("Player %s tried to move, but it's %s's turn", playerID, s.currentTurn)
-		return
-	}
-	
-	currentPlayer := s.ps[addr].id
-	
-	s.game.OpenCell(s.ps[addr].cur)
-	log.Printf("Updated: %s", s.game)
-	
-	// Check if game ended and set winner/loser
-	if s.game.M.State == g.WIN {
-		// Current player wins by opening the last safe cell
-		s.game.M.Winner = currentPlayer
-		log.Printf("Player %s wins by completing the field!", currentPlayer)
-	} else if s.game.M.State == g.OVER {
-		// Current player loses by hitting a mine
-		// The other player wins
-		if currentPlayer == "P1" {
-			s.game.M.Winner = "P2"
-		} else {
-			s.game.M.Winner = "P1"
-		}
-		log.Printf("Player %s hit a mine! Player %s wins!", currentPlayer, s.game.M.Winner)
-	} else if s.game.M.State == g.GAME {
-		// Switch turn only if game continues
-		s.switchTurn()
+// newRoomCode generates a short join code, without look-alike characters
+func newRoomCode() string {
+	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	bs := make([]byte, 5)
+	if _, err := rand.Read(bs); err != nil {
+		panic(err)
+	}
+	for i := range bs {
+		bs[i] = alphabet[int(bs[i])%len(alphabet)]
 	}
-	
-	s.updateAllClients()
-	s.ui.Send(noop{})
+	return string(bs)
 }
 
 func (s *Srv) Run() error {
-	// start WS server
-	log.Print("Server started, waiting for connection from players...")
+	if s.opts.SelfSigned {
+		if s.opts.TLSCert == "" {
+			s.opts.TLSCert = defaultCertFile
+		}
+		if s.opts.TLSKey == "" {
+			s.opts.TLSKey = defaultKeyFile
+		}
+		if err := ensureSelfSignedCert(s.opts.TLSCert, s.opts.TLSKey, s.opts.Addr); err != nil {
+			return err
+		}
+	}
+
+	ln, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("can't listen %s: %w", s.opts.Addr, err)
+	}
+
+	web := webHandler()
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// plain GET requests get the browser client
+			if !isWebSocket(r) {
+				web.ServeHTTP(w, r)
+				return
+			}
 
-	go func() {
-		http.ListenAndServe(":8080", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			conn, _, _, err := ws.UpgradeHTTP(r, w)
 			if err != nil {
 				log.Printf("Error starting socket server: %v", err)
+				return
 			}
 
 			addr := conn.RemoteAddr().String()
 			log.Printf("[%s] Client %s connected", addr, addr)
 
-			go func() {
-				defer func() { _ = conn.Close() }()
-				for {
-					msg, op, err := wsutil.ReadClientData(conn)
-					if err != nil {
-						log.Printf("Error receiving data: " + err.Error())
-						log.Printf("Client %s disconnected", addr)
-						s.disconnectClient(addr)
-						return
-					}
+			go s.handle(conn, addr)
+		}),
+	}
 
-					switch op {
-					case ws.OpText:
-						// join player
-						if !s.connectClient(conn, addr) {
-							// Lobby is full, send error message and close connection
-							errMsg := "LOBBY_FULL: Game lobby is full (max 2 players)"
-							if err := wsutil.WriteServerMessage(conn, ws.OpText, []byte(errMsg)); err != nil {
-								log.Printf("Error sending lobby full message: %s", err.Error())
-							}
-							log.Printf("Connection rejected: lobby full")
-							return
-						}
-
-						// Send player ID as text message
-						playerID := s.ps[addr].id
-						idMsg := fmt.Sprintf("PLAYER_ID:%s", playerID)
-						if err := wsutil.WriteServerMessage(conn, ws.OpText, []byte(idMsg)); err != nil {
-							log.Printf("Error sending player ID: %s", err.Error())
-						}
-
-						// send game state to client
-						if err := wsutil.WriteServerMessage(conn, ws.OpBinary, s.game.Bytes()); err != nil {
-							log.Printf("Error sending data: %s", err.Error())
-							log.Print("Client disconnected")
-							s.ps.disconnect(addr)
-							return
-						}
-
-					case ws.OpBinary:
-						// Binary message handling:
-						// ✓ CursorMove - updates player cursor position
-						// ✓ OpenCell - opens cell and updates all clients (with turn validation)
-						// ✓ Turn-based gameplay (P1 -> P2 -> ...)
-						// Note: FLAG and GESS are client-side markers only
-						// Future enhancements:
-						//  - [ ] Score tracking per player
-
-						e := g.NewEventFromBytes(msg)
-						log.Printf("[%s] %s", addr, e)
-
-						switch e.Type {
-						case g.NoOp:
-							s.ui.Send(noop{})
-						case g.CursorMove:
-							s.updateCursor(addr, e.Position)
-						case g.OpenCell:
-							s.openCell(addr)
-						default:
-							log.Printf("not implemented yet: %s", e.String())
-						}
-					}
-				}
-			}()
-		}))
+	// start WS server
+	go func() {
+		var err error
+		if s.opts.TLSCert != "" {
+			log.Printf("Server started on wss://%s, waiting for connection from players...", s.opts.Addr)
+			err = srv.ServeTLS(ln, s.opts.TLSCert, s.opts.TLSKey)
+		} else {
+			log.Printf("Server started on ws://%s, waiting for connection from players...", s.opts.Addr)
+			err = srv.Serve(ln)
+		}
+		log.Printf("Server stopped: %v", err)
+	}()
+
+	go s.cleanup()
+	go func() {
+		for msg := range s.redraw {
+			s.ui.Send(msg)
+		}
 	}()
 
 	log.Print("UI started")
 	return s.ui.Start()
 }
 
+// handle reads messages of the client connection. The first message should be
+// Hello, the client may ask for the list of rooms before it. After the
+// handshake the client sends only events. The frame type of Hello chooses the
+// codec of the connection, see codecOf. The client is pinged, a silent one is
+// disconnected as if it closed the connection.
+func (s *Srv) handle(conn net.Conn, addr string) {
+	defer func() { _ = conn.Close() }()
+	done := make(chan struct{})
+	defer close(done)
+	go keepAlive(conn, done)
+
+	var r *room      // room of the joined client
+	var token string // session token of the joined client
+	for {
+		data, op, err := wsutil.ReadClientData(aliveConn{conn})
+		if err != nil {
+			log.Printf("Error receiving data: " + err.Error())
+			log.Printf("Client %s disconnected", addr)
+			if r != nil {
+				r.disconnectClient(token, conn)
+			}
+			return
+		}
+
+		codec := codecOf(op)
+		msg, err := codec.Decode(data)
+		if err != nil {
+			log.Printf("[%s] Bad message: %s", addr, err.Error())
+			_ = writeMessage(conn, codec, g.NewError(g.ErrBadRequest, "can't decode message: %s", err.Error()))
+			if r == nil {
+				return
+			}
+			continue
+		}
+
+		switch {
+		case msg.Type == g.MsgRooms && r == nil:
+			if err := writeMessage(conn, codec, g.NewRoomsMessage(s.openRooms())); err != nil {
+				log.Printf("Error sending rooms: %s", err.Error())
+				return
+			}
+
+		case msg.Type == g.MsgHello && r == nil:
+			// join player
+			var errMsg *g.Message
+			if r, token, errMsg = s.connectClient(conn, addr, codec, msg.Hello); errMsg != nil {
+				if err := writeMessage(conn, codec, errMsg); err != nil {
+					log.Printf("Error sending error message: %s", err.Error())
+				}
+				log.Printf("Connection rejected: %s", errMsg.Error)
+				return
+			}
+			log.Printf("[%s] Joined room %s with %s codec", addr, r.code, codec)
+
+			// Send player ID, session token and game state to client
+			if err := r.welcome(token); err != nil {
+				log.Printf("Error sending data: %s", err.Error())
+				log.Print("Client disconnected")
+				r.disconnectClient(token, conn)
+				return
+			}
+
+		case msg.Type == g.MsgEvent && r != nil:
+			r.handleEvent(token, msg.Event)
+
+		default:
+			log.Printf("[%s] Unexpected %s message", addr, msg.Type)
+			_ = writeMessage(conn, codec, g.NewError(g.ErrBadRequest, "unexpected %s message", msg.Type))
+			if r == nil {
+				return
+			}
+		}
+	}
+}
+
 type serverUIModel struct {
-	*g.Model
-	s   *Srv
-	dbg bool
+	*g.Model           // game of the shown room, set by View
+	r        *roomView // copy of the shown room, set by View
+	s        *Srv
+	dbg      bool
+	selected string // code of the room to show, the first room if it's gone
 }
 
 func (m serverUIModel) Init() tea.Cmd {
-	return tea.EnterAltScreen
+	return tea.Batch(tea.EnterAltScreen, tickEverySecond())
 }
 
 func (m serverUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg.(type) {
+	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if msg.Type == tea.KeyTab {
+			m.selected = m.nextRoom()
+			return m, nil
+		}
 		return m, tea.Quit
 	case noop:
 		return m, nil
+	case tick:
+		return m, tickEverySecond()
 	case player:
 		return m, nil
 	default:
@@ -378,11 +632,53 @@ func (m serverUIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 }
 
+// nextRoom returns the code of the room after the selected one
+func (m serverUIModel) nextRoom() string {
+	rooms := m.s.roomList()
+	for i, r := range rooms {
+		if r.code == m.selected && i+1 < len(rooms) {
+			return rooms[i+1].code
+		}
+	}
+	if len(rooms) > 0 {
+		return rooms[0].code
+	}
+	return ""
+}
+
 func (m serverUIModel) View() string {
+	rooms := m.s.roomList()
+	var shown *room
+	infos := make([]g.RoomInfo, 0, len(rooms))
+	for _, r := range rooms {
+		if r.code == m.selected || shown == nil {
+			shown = r
+		}
+		r.mu.Lock()
+		infos = append(infos, r.info())
+		r.mu.Unlock()
+	}
+	if shown == nil {
+		return strings.Join([]string{
+			m.titleFrame(),
+			"",
+			"Waiting for players...",
+			LogsWidget(m, 10),
+		}, "\n")
+	}
+	v := shown.snapshot()
+	m.r = &v
+	m.Model = v.game
+
+	field := m.fieldFrame()
+	if m.State == g.LOBBY {
+		field = lobbyFrame(m.Model, "")
+	}
 	frames := []string{
 		m.titleFrame(),
-		m.fieldFrame(),
+		field,
 		m.playersFrame(),
+		m.roomsFrame(infos),
 		LogsWidget(m, 10),
 	}
 	return strings.Join(frames, "\n")
@@ -393,58 +689,78 @@ func (m serverUIModel) GetLogs() []string {
 }
 
 func (m serverUIModel) titleFrame() string {
-	return strings.Join([]string{
+	title := []string{
 		"     *** Minesweeper ***",
 		"     ===================",
-	}, "\n")
+	}
+	if m.r != nil {
+		title = append(title, "     Room: "+m.r.info.Code)
+	}
+	// the board is voted for in the lobby
+	if m.r != nil && m.State != g.LOBBY {
+		title = append(title,
+			"     Board: "+m.r.info.Board,
+			"     Mode: "+m.Mode.String(),
+			"     Seed: "+m.r.seed,
+		)
+	}
+	return strings.Join(title, "\n")
+}
+
+// roomsFrame lists all rooms, the shown one is marked
+func (m serverUIModel) roomsFrame(infos []g.RoomInfo) string {
+	lines := []string{"", "Rooms (Tab to switch):"}
+	for _, info := range infos {
+		mark := " "
+		if info.Code == m.r.info.Code {
+			mark = ">"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s  %s  players: %d/%d  spectators: %d  %s",
+			mark, info.Code, info.Board, info.Players, info.MaxPlayers, info.Spectators, g.StateTitle(info.State)))
+	}
+	return strings.Join(lines, "\n")
 }
 
 func (m serverUIModel) fieldFrame() string {
 	var frames []string
 
-	var p1Cur, p2Cur *g.Point
-	if p1 := m.s.ps.getByID("P1"); p1 != nil {
-		p1Cur = &p1.cur
-	}
-	if p2 := m.s.ps.getByID("P2"); p2 != nil {
-		p2Cur = &p2.cur
-	}
-
 	for r := 0; r < m.N; r++ {
 		var line string
 		for c := 0; c < m.M; c++ {
-			// player cursors marks
+			// player cursors marks, the first player's bracket is on the left
+			// and the last one's is on the right if cursors overlap
 			lo, hi := " ", " "
 			{
-				if p1Cur != nil {
-					if r == p1Cur[0] && c == p1Cur[1] {
-						lo = P1Style("[")
-						hi = P1Style("]")
+				var here []string
+				for _, id := range m.Players {
+					if p, ok := m.r.players[id]; ok && p.cur[0] == r && p.cur[1] == c {
+						here = append(here, id)
 					}
 				}
-				if p2Cur != nil {
-					if r == p2Cur[0] && c == p2Cur[1] {
-						lo = P2Style("[")
-						hi = P2Style("]")
-					}
-				}
-				if p1Cur != nil && p2Cur != nil {
-					if p1Cur[0] == p2Cur[0] && p1Cur[1] == p2Cur[1] {
-						if r == p1Cur[0] && c == p1Cur[1] {
-							lo = P1Style("[")
-						}
-					}
+				if len(here) > 0 {
+					lo = playerStyle(here[0])("[")
+					hi = playerStyle(here[len(here)-1])("]")
 				}
 			}
 
 			// mines
-			cell := m.Field[r][c]
+			cell := string(m.Field[r][c])
+			if mark := styledMark(m.Model, g.Point{r, c}, ""); mark != "" {
+				cell = mark
+			}
 			if m.Mines[r][c] == g.MINE {
-				cell = g.MINE
+				cell = string(g.MINE)
+			}
+			if id := m.PingedBy(g.Point{r, c}); id != "" {
+				v := m.Field[r][c]
+				if m.Mines[r][c] == g.MINE {
+					v = g.MINE
+				}
+				cell = pingStyle(id)(string(v))
 			}
 
 			line += lo
-			line += string(cell)
+			line += cell
 			line += hi
 		}
 		frames = append(frames, line)
@@ -454,32 +770,53 @@ func (m serverUIModel) fieldFrame() string {
 
 func (m serverUIModel) playersFrame() string {
 	var ps []string
-	
+
 	// Show winner if game is over
-	if m.State == g.WIN || m.State == g.OVER {
+	if m.IsOver() && m.Mode == g.COOP {
+		ps = append(ps, teamFrame(m.Model))
+	} else if m.State == g.WIN || m.State == g.OVER {
 		if m.Winner != "" {
-			winnerMsg := fmt.Sprintf("🎉 Winner: %s 🎉", m.Winner)
-			if m.Winner == "P1" {
-				winnerMsg = "🎉 Winner: " + P1Style("P1") + " 🎉"
-			} else if m.Winner == "P2" {
-				winnerMsg = "🎉 Winner: " + P2Style("P2") + " 🎉"
-			}
+			winnerMsg := "🎉 Winner: " + playerStyle(m.Winner)(m.Winner) + " 🎉"
 			ps = append(ps, "", winnerMsg)
 		}
+	} else if m.State == g.GAME && m.Mode == g.COOP {
+		ps = append(ps, "Co-op: everybody opens cells at any time")
+	} else if m.State == g.GAME && m.Mode == g.RACE {
+		ps = append(ps, "Race: everybody clears a copy of the board")
 	} else if m.State == g.GAME {
 		// Show current turn only during active gameplay
-		turnMsg := fmt.Sprintf("Current Turn: %s", m.s.currentTurn)
-		if m.s.currentTurn == "P1" {
-			turnMsg = "Current Turn: " + P1Style("P1")
-		} else if m.s.currentTurn == "P2" {
-			turnMsg = "Current Turn: " + P2Style("P2")
-		}
+		turnMsg := "Current Turn: " + playerStyle(m.r.currentTurn)(m.r.currentTurn)
 		ps = append(ps, turnMsg)
+	} else if m.State == g.PAUSE {
+		ps = append(ps, fmt.Sprintf("Paused: %s, forfeit in %s", m.Paused, countdown(m.Model, m.PausedUntil)))
 	}
-	
+
 	ps = append(ps, "")
-	for _, v := range m.s.ps {
-		ps = append(ps, v.String())
+	for _, id := range m.Players {
+		v, ok := m.r.players[id]
+		if !ok {
+			continue
+		}
+		line := v.String()
+		if m.IsEliminated(id) {
+			line += " " + RedStyle("OUT")
+		}
+		ps = append(ps, line)
+	}
+	if m.r.info.Spectators > 0 {
+		ps = append(ps, fmt.Sprintf("Spectators: %d", m.r.info.Spectators))
+	}
+	if clock := clockFrame(m.Model); clock != "" {
+		ps = append(ps, clock)
+	}
+	if m.Mode == g.RACE && m.State != g.LOBBY {
+		ps = append(ps, raceFrame(m.Model, ""))
+	}
+	if m.Mode == g.FLAGS && m.State != g.LOBBY {
+		ps = append(ps, flagsFrame(m.Model, ""))
+	}
+	if series := seriesFrame(m.Model); series != "" {
+		ps = append(ps, series)
 	}
 	return strings.Join(ps, "\n")
 }
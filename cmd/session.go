@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// sessionsFile keeps session tokens of the client by server address, so a
+// restarted client reclaims its slot in the game
+func sessionsFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "minesweeper", "sessions.json"), nil
+}
+
+func loadSessions() (map[string]string, error) {
+	file, err := sessionsFile()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string]string)
+	bs, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return sessions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read sessions: %w", err)
+	}
+	if err := json.Unmarshal(bs, &sessions); err != nil {
+		return nil, fmt.Errorf("can't parse sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// loadToken returns the saved session token for the server or ""
+func loadToken(serverAddr string) string {
+	sessions, err := loadSessions()
+	if err != nil {
+		return ""
+	}
+	return sessions[serverAddr]
+}
+
+// saveToken saves the session token for the server
+func saveToken(serverAddr, token string) error {
+	sessions, err := loadSessions()
+	if err != nil {
+		sessions = make(map[string]string)
+	}
+	sessions[serverAddr] = token
+
+	file, err := sessionsFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return fmt.Errorf("can't save session: %w", err)
+	}
+	bs, err := json.Marshal(sessions)
+	if err != nil {
+		return fmt.Errorf("can't save session: %w", err)
+	}
+	return os.WriteFile(file, bs, 0o600)
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// web is the browser client, it talks to the server with JSON messages
+//
+//go:embed web
+var web embed.FS
+
+// webHandler serves the browser client files
+func webHandler() http.Handler {
+	root, err := fs.Sub(web, "web")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(root))
+}
+
+// isWebSocket reports whether the request asks to upgrade to WebSocket
+func isWebSocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
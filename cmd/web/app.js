@@ -0,0 +1,587 @@
+// Browser client of the minesweeper server. It speaks the same protocol as
+// cmd/client.go using JSON messages in text frames (see pkg/proto.go).
+"use strict";
+
+const PROTOCOL_VERSION = 1;
+
+// message types
+const HELLO = 1, WELCOME = 2, ERROR = 3, STATE = 4, DELTA = 5, EVENT = 6, ROOMS = 7, CHAT_LOG = 8;
+
+// event types
+const CURSOR_MOVE = 1, OPEN_CELL = 2, CHORD = 3, TOGGLE_FLAG = 4, TOGGLE_GUESS = 5, RESYNC = 6,
+  TOGGLE_READY = 7, CAST_VOTE = 8, REMATCH = 9, CHAT = 10, PING_CELL = 11;
+
+// game states
+const GAME = 0, OVER = 1, WIN = 2, PAUSE = 3, LOBBY = 4;
+
+const DIFFICULTIES = ["EASY", "NORMAL", "HARD", "CUSTOM"];
+
+// game modes
+const VERSUS = 0, COOP = 1, RACE = 2, FLAGS = 3;
+const MODES = ["VERSUS", "COOP", "RACE", "FLAGS"];
+
+const HIDE = "~", BOOM = "X", EMPTY = " ";
+
+let ws = null;
+let playerID = "";
+let game = null;
+let seq = 0;
+let resync = false;
+let rejected = false;
+let spectator = false;
+let chat = []; // recent messages of the room chat
+let clockOffset = 0; // of the server clock from the local one, in ms
+
+// open the page with ?spectate to watch the game, ?room=CODE to join the room,
+// ?create to create a new room and ?rooms to list open rooms
+const params = new URLSearchParams(location.search);
+const spectate = params.has("spectate");
+const listRooms = params.has("rooms");
+let room = (params.get("room") || "").toUpperCase();
+let create = params.has("create");
+let cur = [0, 0];
+let welcomed = false; // the next state is the first one after the welcome
+
+const $ = (id) => document.getElementById(id);
+
+function log(text) {
+  const logs = $("logs");
+  logs.textContent = (new Date().toLocaleTimeString() + " " + text + "\n" + logs.textContent)
+    .split("\n").slice(0, 10).join("\n");
+}
+
+// serverNow returns the current time of the server clock in ms. Deadlines of
+// the game are set by the server, they are counted down by its clock.
+function serverNow() {
+  return Date.now() + clockOffset;
+}
+
+// syncClock sets the server clock by the server time sent with the update
+function syncClock(now) {
+  if (now) {
+    clockOffset = new Date(now) - Date.now();
+  }
+}
+
+function send(msg) {
+  if (ws && ws.readyState === WebSocket.OPEN) {
+    ws.send(JSON.stringify(msg));
+  }
+}
+
+function sendEvent(type) {
+  send({Type: EVENT, Event: {Type: type, Position: cur}});
+}
+
+function sendVote(vote) {
+  send({Type: EVENT, Event: {Type: CAST_VOTE, Position: cur, Vote: vote}});
+}
+
+function sendChat(text) {
+  send({Type: EVENT, Event: {Type: CHAT, Position: cur, Text: text}});
+}
+
+function voteTitle(v) {
+  return DIFFICULTIES[v.Difficulty] + (v.NoGuess ? ", no guess" : "") + ", " + MODES[v.Mode];
+}
+
+function connect() {
+  const proto = location.protocol === "https:" ? "wss://" : "ws://";
+  ws = new WebSocket(proto + location.host + "/");
+
+  ws.onopen = () => {
+    log("connected!");
+    if (listRooms) {
+      send({Type: ROOMS, Rooms: {}});
+      return;
+    }
+    const token = spectate ? "" : sessionStorage.getItem("token") || "";
+    send({Type: HELLO, Hello: {Version: PROTOCOL_VERSION, Capabilities: ["delta", "chord", "markers"], Token: token, Spectate: spectate, Room: room, Create: create}});
+  };
+  ws.onclose = () => {
+    log("disconnected");
+    if (listRooms || rejected) {
+      $("status").textContent = "Disconnected from the server";
+      return;
+    }
+    $("status").textContent = "Connection lost, reconnecting...";
+    setTimeout(connect, 2000);
+  };
+  ws.onmessage = (e) => handle(JSON.parse(e.data));
+}
+
+function handle(msg) {
+  switch (msg.Type) {
+    case WELCOME:
+      if (msg.Welcome.Version !== PROTOCOL_VERSION) {
+        log("server speaks protocol v" + msg.Welcome.Version);
+        ws.close();
+        return;
+      }
+      playerID = msg.Welcome.PlayerID;
+      // rejoin the same room on reconnect
+      room = msg.Welcome.Room;
+      create = false;
+      $("room").innerHTML = "Room <a href=\"?room=" + room + "\">" + room + "</a>";
+      spectator = !!msg.Welcome.Spectator;
+      welcomed = true;
+      $("chat-input").hidden = spectator;
+      // the server sends the chat history after the welcome
+      chat = [];
+      renderChat();
+      if (spectator) {
+        log("Watching the game as " + playerID);
+        break;
+      }
+      sessionStorage.setItem("token", msg.Welcome.Token);
+      log("Assigned Player ID: " + playerID);
+      break;
+
+    case ROOMS:
+      renderRooms(msg.Rooms.List || []);
+      ws.close();
+      break;
+
+    case CHAT_LOG:
+      chat = chat.concat(msg.Chat.Messages || []).slice(-20);
+      renderChat();
+      return;
+
+    case ERROR:
+      log("Server error: " + msg.Error.Code + ": " + msg.Error.Text);
+      $("status").textContent = msg.Error.Text;
+      // the server may not have noticed the lost connection of the slot yet
+      rejected = msg.Error.Code !== "SESSION_IN_USE";
+      break;
+
+    case STATE:
+      game = msg.State.Game;
+      seq = msg.State.Seq;
+      resync = false;
+      syncClock(msg.State.Now);
+      // the server keeps the cursor of a reclaimed slot
+      if (welcomed) {
+        welcomed = false;
+        cur = (game.M.Cursors || {})[playerID] || [0, 0];
+      }
+      break;
+
+    case DELTA:
+      if (resync) {
+        return;
+      }
+      if (!game || msg.Delta.Seq !== seq + 1) {
+        log("Missed update #" + (seq + 1) + ", resync");
+        resync = true;
+        sendEvent(RESYNC);
+        return;
+      }
+      apply(msg.Delta);
+      seq = msg.Delta.Seq;
+      break;
+  }
+  render();
+}
+
+function apply(d) {
+  const m = game.M;
+  for (const c of d.Cells || []) {
+    m.Field[c.P[0]][c.P[1]] = c.V;
+  }
+  m.Marks = m.Marks || {};
+  for (const mk of d.Markers || []) {
+    if (!m.Marks[mk.Player]) {
+      m.Marks[mk.Player] = m.Field.map((row) => row.map(() => HIDE.codePointAt(0)));
+    }
+    m.Marks[mk.Player][mk.P[0]][mk.P[1]] = mk.V;
+  }
+  m.Cursors = d.Cursors;
+  m.LeftToOpen = d.LeftToOpen;
+  m.State = d.State;
+  m.Winner = d.Winner;
+  m.CurrentTurn = d.CurrentTurn;
+  m.Players = d.Players;
+  m.Eliminated = d.Eliminated;
+  m.Score = d.Score;
+  m.TurnStarted = d.TurnStarted;
+  m.TurnUntil = d.TurnUntil;
+  m.Clocks = d.Clocks;
+  m.Pings = d.Pings;
+  m.Guessing = d.Guessing;
+  syncClock(d.Now);
+}
+
+// mark returns the marker of the cell, own markers win over others
+function mark(r, c) {
+  const m = game.M;
+  if (String.fromCodePoint(m.Field[r][c]) !== HIDE || !m.Marks) {
+    return null;
+  }
+  const ids = Object.keys(m.Marks).sort();
+  ids.sort((a, b) => (b === playerID) - (a === playerID));
+  for (const id of ids) {
+    const v = String.fromCodePoint(m.Marks[id][r][c]);
+    if (v !== HIDE) {
+      return {id: id, v: v};
+    }
+  }
+  return null;
+}
+
+// pingedBy returns the player pinging the cell at the moment, the latest ping
+// wins, or "" if nobody does
+function pingedBy(m, r, c) {
+  let by = "", until = serverNow();
+  for (const id in m.Pings || {}) {
+    const p = m.Pings[id];
+    const t = new Date(p.Until).getTime();
+    if (p.P[0] === r && p.P[1] === c && t > until) {
+      by = id;
+      until = t;
+    }
+  }
+  return by;
+}
+
+// pingsShown reports whether a ping is highlighted or has just expired and
+// the field should be redrawn
+function pingsShown(m) {
+  return Object.values(m.Pings || {}).some((p) => new Date(p.Until).getTime() > serverNow() - 1000);
+}
+
+function render() {
+  if (!game) {
+    return;
+  }
+  const m = game.M;
+  const field = $("field");
+  field.innerHTML = "";
+  if (m.State === LOBBY) {
+    renderLobby(m);
+    return;
+  }
+  $("lobby").innerHTML = "";
+  $("race").innerHTML = m.Mode === RACE ? raceProgress(m) : "";
+  $("board").textContent = DIFFICULTIES[game.Difficulty] + " " + m.N + "x" + m.M + ", " + m.MinesCount + " mines, " + MODES[m.Mode] +
+    (m.Guessing ? ", guessing may be required" : m.NoGuess ? ", no guess" : "");
+
+  for (let r = 0; r < m.N; r++) {
+    const row = field.insertRow();
+    for (let c = 0; c < m.M; c++) {
+      const td = row.insertCell();
+      const v = String.fromCodePoint(m.Field[r][c]);
+      td.textContent = v === HIDE ? "" : v;
+      if (v === HIDE) {
+        td.classList.add("hide");
+      } else if (v === BOOM) {
+        td.classList.add("boom");
+      } else if (v >= "1" && v <= "8") {
+        td.classList.add("n" + v);
+      }
+
+      const mk = mark(r, c);
+      if (mk) {
+        td.textContent = mk.v;
+        td.classList.add(mk.id);
+      }
+
+      for (const id in m.Cursors || {}) {
+        const p = m.Cursors[id];
+        if (id !== playerID && p[0] === r && p[1] === c) {
+          td.classList.add(id);
+        }
+      }
+      const by = pingedBy(m, r, c);
+      if (by) {
+        td.classList.add("ping-" + by);
+      }
+      if (spectator) {
+        continue;
+      }
+      if (cur[0] === r && cur[1] === c) {
+        td.classList.add("own");
+      }
+
+      td.onclick = () => {
+        moveTo(r, c);
+        sendEvent(v >= "1" && v <= "8" ? CHORD : OPEN_CELL);
+      };
+      td.oncontextmenu = (e) => {
+        e.preventDefault();
+        moveTo(r, c);
+        sendEvent(TOGGLE_FLAG);
+      };
+    }
+  }
+
+  let status = (spectator ? "Watching as " : "You are ") + playerID + ". ";
+  const out = m.Eliminated || [];
+  if (m.State === GAME && m.Mode === COOP) {
+    status += "Co-op: everybody opens cells at any time.";
+  } else if (m.State === GAME && m.Mode === RACE) {
+    status += "Race: clear your board first, a mine puts you out.";
+    if (out.includes(playerID)) {
+      status += " You hit a mine and are out of the race.";
+    }
+  } else if (m.State === GAME) {
+    status += m.CurrentTurn === playerID ? "Your turn!" : "Current Turn: " + m.CurrentTurn;
+    if (out.includes(playerID)) {
+      status += " You hit a mine and are out of the game.";
+    }
+  } else if (m.State === PAUSE) {
+    status += "Game paused: " + m.Paused;
+  } else if (m.Mode === COOP) {
+    status += (m.State === WIN ? "🎉 TEAM WINS! 🎉" : "💥 TEAM LOST 💥") + " Contribution: " + contribution(m) + ".";
+  } else if (m.Winner) {
+    status += "🎉 " + m.Winner + " WINS! 🎉 " + (spectator ? "" : m.Winner === playerID ? "Congratulations! You won!" : "Better luck next time!");
+  } else {
+    status += m.State === WIN ? "GAME WON!" : "GAME OVER";
+  }
+  if (out.length > 0) {
+    status += " Out of the game: " + out.join(", ");
+  }
+  if (m.Mode === FLAGS) {
+    status += " " + flagsScore(m);
+  }
+  status += clocks(m);
+  status += seriesStatus(m);
+  // the seed gives the mine layout away, so the server sends it after the game only
+  if ((m.State === OVER || m.State === WIN) && game.Seed && game.Seed !== "0") {
+    status += " Seed: " + seedCode(game) + ".";
+  }
+  $("status").textContent = status;
+}
+
+// seedCode formats the seed like Game.SeedCode, e.g. "K2J9XQ-4-4". The seed
+// is a string in JSON, it doesn't fit into a number.
+function seedCode(g) {
+  let code = BigInt(g.Seed).toString(36).toUpperCase();
+  if (g.M.Placed) {
+    code += "-" + g.First[0] + "-" + g.First[1];
+  }
+  return code;
+}
+
+// raceProgress draws how much of the private board each racer has opened
+function raceProgress(m) {
+  const total = m.N * m.M - m.MinesCount;
+  let html = "";
+  for (const id of m.Players || []) {
+    const left = (m.Progress || {})[id] || 0;
+    const percent = total > 0 ? Math.floor((total - left) * 100 / total) : 0;
+    const filled = Math.floor(percent / 5);
+    html += "<p><span class=\"" + id + "\">" + id + "</span>" + (id === playerID ? " (you)" : "") +
+      " [" + "#".repeat(filled) + "-".repeat(20 - filled) + "] " + percent + "%" +
+      ((m.Eliminated || []).includes(id) ? " OUT" : "") + "</p>";
+  }
+  return html;
+}
+
+// secondsLeft returns whole seconds until the time, 0 for a zero time
+function secondsLeft(t) {
+  return Math.max(0, Math.ceil((new Date(t) - serverNow()) / 1000));
+}
+
+// clocks describes the time left for the current move and chess clocks of
+// players, Clocks are in nanoseconds
+function clocks(m) {
+  if (m.State !== GAME && m.State !== PAUSE) {
+    return "";
+  }
+  let status = "";
+  if (m.TurnUntil && new Date(m.TurnUntil).getFullYear() > 1) {
+    status += " Move time left: " + secondsLeft(m.TurnUntil) + "s.";
+  }
+  if (m.Clocks) {
+    status += " Clocks: " + (m.Players || []).map((id) => {
+      let left = (m.Clocks[id] || 0) / 1e6;
+      if (id === m.CurrentTurn && new Date(m.TurnStarted).getFullYear() > 1) {
+        left -= serverNow() - new Date(m.TurnStarted);
+      }
+      const s = Math.max(0, Math.round(left / 1000));
+      return id + " " + Math.floor(s / 60) + ":" + String(s % 60).padStart(2, "0");
+    }).join(", ") + ".";
+  }
+  return status;
+}
+
+// flagsScore describes mines found by each player in FLAGS
+function flagsScore(m) {
+  const score = m.Score || {};
+  let status = "Mines found (" + (Math.floor(m.MinesCount / 2) + 1) + " of " + m.MinesCount + " to win): " +
+    (m.Players || []).map((id) => id + " " + (score[id] || 0)).join(", ") + ".";
+  if ((m.State === OVER || m.State === WIN) && !m.Winner) {
+    status += " All mines are found, it's a draw.";
+  }
+  return status;
+}
+
+// contribution describes cells opened by each player of the COOP team
+function contribution(m) {
+  const opened = m.Opened || {};
+  const total = (m.Players || []).reduce((sum, id) => sum + (opened[id] || 0), 0);
+  return (m.Players || []).map((id) => {
+    const n = opened[id] || 0;
+    return id + " " + n + " cells (" + (total > 0 ? Math.floor(n * 100 / total) : 0) + "%)";
+  }).join(", ");
+}
+
+// seriesStatus describes the scoreboard of the series and rematch requests
+function seriesStatus(m) {
+  const s = m.Series;
+  if (!s) {
+    return "";
+  }
+  let status = " Series: Round " + s.Round + (s.BestOf > 0 ? " of " + s.BestOf : "");
+  for (const id of Object.keys(s.Score || {}).sort()) {
+    status += ", " + id + " " + s.Score[id];
+  }
+  status += ".";
+  if (s.Winner) {
+    status += " 🏆 " + s.Winner + " wins the series! 🏆";
+  }
+  if (s.Draw) {
+    status += " The series is a draw.";
+  }
+  if (m.State === OVER || m.State === WIN) {
+    const asked = (m.Players || []).filter((id) => (s.Rematch || {})[id]);
+    if (asked.length > 0) {
+      status += " Rematch asked by: " + asked.join(", ") + ".";
+    }
+    if (!spectator) {
+      status += " Press R for a rematch.";
+    }
+  }
+  return status;
+}
+
+function renderLobby(m) {
+  $("race").innerHTML = "";
+  $("board").textContent = "Lobby: vote for the board and get ready";
+  let html = "";
+  for (const id of m.Players || []) {
+    const ready = (m.Ready || {})[id] ? "ready" : "not ready";
+    const vote = (m.Votes || {})[id];
+    html += "<p><span class=\"" + id + "\">" + id + "</span>" + (id === playerID ? " (you)" : "") + ": " + ready +
+      (vote ? ", votes for " + voteTitle(vote) : "") + "</p>";
+  }
+  html += "<p>Board: " + voteTitle(m.Choice) + "</p>";
+  $("lobby").innerHTML = html;
+
+  let status = (spectator ? "Watching as " : "You are ") + playerID + ". ";
+  const left = Math.ceil((new Date(m.StartsAt) - serverNow()) / 1000);
+  // zero StartsAt is far in the past, so no countdown
+  if (left > 0) {
+    status += "Starting in " + left + "s";
+  } else {
+    status += "Waiting for everybody to get ready...";
+  }
+  $("status").textContent = status;
+}
+
+function renderChat() {
+  const pane = $("chat-log");
+  pane.textContent = "";
+  for (const msg of chat) {
+    const line = document.createElement("div");
+    const from = document.createElement("span");
+    from.className = msg.From;
+    from.textContent = new Date(msg.At).toLocaleTimeString([], {hour: "2-digit", minute: "2-digit"}) + " " + msg.From + ": ";
+    line.append(from, msg.Text);
+    pane.append(line);
+  }
+  pane.scrollTop = pane.scrollHeight;
+}
+
+function renderRooms(list) {
+  let html = "<p><a href=\"?create\">Create a new room</a></p>";
+  if (list.length === 0) {
+    html += "<p>No open rooms</p>";
+  }
+  for (const r of list) {
+    html += "<p>" + r.Code + " &middot; " + r.Board + " &middot; players: " + r.Players + "/" + r.MaxPlayers +
+      " &middot; <a href=\"?room=" + r.Code + "\">join</a> &middot; <a href=\"?room=" + r.Code + "&spectate\">watch</a></p>";
+  }
+  $("room").innerHTML = html;
+  $("status").textContent = "";
+}
+
+function moveTo(r, c) {
+  if (cur[0] !== r || cur[1] !== c) {
+    cur = [r, c];
+    sendEvent(CURSOR_MOVE);
+  }
+}
+
+$("chat-input").addEventListener("keydown", (e) => {
+  const input = e.target;
+  if (e.key === "Enter" && input.value.trim() !== "") {
+    sendChat(input.value);
+  }
+  if (e.key === "Enter" || e.key === "Escape") {
+    input.value = "";
+    input.blur();
+  }
+  // keys typed in the chat don't play the game
+  e.stopPropagation();
+});
+
+document.addEventListener("keydown", (e) => {
+  if (!game || spectator) {
+    return;
+  }
+  if (e.key === "t") {
+    e.preventDefault();
+    $("chat-input").focus();
+    return;
+  }
+  const m = game.M;
+  if (m.State === LOBBY) {
+    const vote = Object.assign({}, (m.Votes || {})[playerID] || m.Choice);
+    switch (e.key) {
+      case "1": case "2": case "3": vote.Difficulty = Number(e.key) - 1; sendVote(vote); break;
+      case "4": if (game.Difficulty === 3) { vote.Difficulty = 3; sendVote(vote); } break;
+      case "n": vote.NoGuess = !vote.NoGuess; sendVote(vote); break;
+      case "m": vote.Mode = (vote.Mode + 1) % MODES.length; sendVote(vote); break;
+      case "r": sendEvent(TOGGLE_READY); break;
+      default: return;
+    }
+    e.preventDefault();
+    return;
+  }
+  if (m.State === OVER || m.State === WIN) {
+    if (e.key === "r") {
+      e.preventDefault();
+      sendEvent(REMATCH);
+    } else if (e.key === "p") {
+      e.preventDefault();
+      sendEvent(PING_CELL);
+    }
+    return;
+  }
+  let [r, c] = cur;
+  switch (e.key) {
+    case "ArrowUp": case "w": r = Math.max(r - 1, 0); break;
+    case "ArrowDown": case "s": r = Math.min(r + 1, m.N - 1); break;
+    case "ArrowLeft": case "a": c = Math.max(c - 1, 0); break;
+    case "ArrowRight": case "d": c = Math.min(c + 1, m.M - 1); break;
+    case " ": sendEvent(OPEN_CELL); break;
+    case "Enter": sendEvent(TOGGLE_FLAG); break;
+    case "g": sendEvent(TOGGLE_GUESS); break;
+    case "c": sendEvent(CHORD); break;
+    case "p": sendEvent(PING_CELL); break;
+    default: return;
+  }
+  e.preventDefault();
+  moveTo(r, c);
+  render();
+});
+
+// keep the lobby countdown and move clocks ticking, expire pings
+setInterval(() => {
+  if (game && (game.M.State === LOBBY || (game.M.State === GAME && clocks(game.M) !== "") || pingsShown(game.M))) {
+    render();
+  }
+}, 1000);
+
+connect();
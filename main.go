@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/egregors/minesweeper/cmd"
 	g "github.com/egregors/minesweeper/pkg"
@@ -11,10 +12,34 @@ import (
 )
 
 type Opts struct {
-	Server bool `short:"s" long:"server" description:"Run as server"`
-	Client bool `short:"c" long:"client" description:"Run as client"`
-	Addr   string `short:"a" long:"addr" default:"127.0.0.1:8080" description:"Server address (for client mode) or bind address (for server mode)"`
-	Dbg    bool `long:"debug" env:"DEBUG" description:"Enable debug mode"`
+	Server         bool          `short:"s" long:"server" description:"Run as server"`
+	Client         bool          `short:"c" long:"client" description:"Run as client"`
+	Addr           string        `short:"a" long:"addr" default:"127.0.0.1:8080" description:"Server address (for client mode) or bind address (for server mode)"`
+	Difficulty     string        `long:"difficulty" default:"easy" choice:"easy" choice:"normal" choice:"hard" choice:"custom" description:"Board difficulty (server mode)"`
+	Rows           int           `long:"rows" description:"Rows of the custom board (server mode)"`
+	Cols           int           `long:"cols" description:"Columns of the custom board (server mode)"`
+	Mines          int           `long:"mines" description:"Mines count of the custom board (server mode)"`
+	Seed           string        `long:"seed" description:"Seed code of the board to replay, with the first opened cell, e.g. K2J9XQ-4-4 (server mode)"`
+	NoGuess        bool          `long:"no-guess" description:"Generate boards solvable without guessing (server mode)"`
+	Mode           string        `long:"mode" default:"versus" choice:"versus" choice:"coop" choice:"race" choice:"flags" description:"Game mode: players take turns, open cells together, race on copies of the board or compete for mines (server mode)"`
+	Players        int           `long:"players" default:"2" description:"Number of players, they take turns in join order (server mode)"`
+	TLSCert        string        `long:"tls-cert" description:"TLS certificate file to serve wss:// (server mode)"`
+	TLSKey         string        `long:"tls-key" description:"TLS key file (server mode)"`
+	SelfSigned     bool          `long:"self-signed" description:"Generate a self-signed TLS certificate on the first start (server mode)"`
+	SpectatorDelay time.Duration `long:"spectator-delay" description:"Delay of the game updates sent to spectators (server mode)"`
+	Grace          time.Duration `long:"grace" default:"30s" description:"How long to wait for a disconnected player before the game is forfeited, 0 ends it at once (server mode)"`
+	TurnTime       time.Duration `long:"turn-time" description:"Time limit of each move, 0 for no limit (server mode)"`
+	OnTimeout      string        `long:"on-timeout" default:"skip" choice:"skip" choice:"forfeit" description:"What happens to the move out of time: the turn is skipped or the player is out of the game (server mode)"`
+	Clock          time.Duration `long:"clock" description:"Chess clock budget of each player for the whole game, 0 for no clock (server mode)"`
+	BestOf         int           `long:"best-of" description:"Number of games in a series of rematches, 0 for an endless series (server mode)"`
+	TLS            bool          `long:"tls" description:"Connect with wss:// (client mode)"`
+	Insecure       bool          `long:"insecure-skip-verify" description:"Don't verify the server TLS certificate (client mode)"`
+	Spectate       bool          `long:"spectate" description:"Watch the game as a spectator (client mode)"`
+	Resume         bool          `long:"resume" description:"Reclaim the slot of the last session after a restart (client mode)"`
+	Room           string        `long:"room" description:"Code of the room to join (client mode)"`
+	Create         bool          `long:"create" description:"Create a new room (client mode)"`
+	Rooms          bool          `long:"rooms" description:"List open rooms of the server and exit (client mode)"`
+	Dbg            bool          `long:"debug" env:"DEBUG" description:"Enable debug mode"`
 }
 
 func main() {
@@ -38,8 +63,46 @@ func main() {
 	}
 
 	if opts.Server {
-		game := g.NewGame(g.EASY, true)
-		if err := cmd.NewServer(game, logger, opts.Dbg).Run(); err != nil {
+		settings, err := gameSettings(opts)
+		if err != nil {
+			fmt.Printf("cli error: %v", err)
+			os.Exit(2)
+		}
+
+		if opts.Players < 1 || opts.Players > cmd.MAX_PLAYERS {
+			fmt.Printf("cli error: --players should be in [1, %d], got %d", cmd.MAX_PLAYERS, opts.Players)
+			os.Exit(2)
+		}
+
+		if opts.TurnTime < 0 || opts.Clock < 0 {
+			fmt.Printf("cli error: --turn-time and --clock should not be negative")
+			os.Exit(2)
+		}
+
+		if opts.BestOf < 0 {
+			fmt.Printf("cli error: --best-of should not be negative, got %d", opts.BestOf)
+			os.Exit(2)
+		}
+
+		if (opts.TLSCert == "") != (opts.TLSKey == "") {
+			fmt.Printf("cli error: --tls-cert and --tls-key should be set together")
+			os.Exit(2)
+		}
+
+		srvOpts := cmd.ServerOpts{
+			Addr:           opts.Addr,
+			TLSCert:        opts.TLSCert,
+			TLSKey:         opts.TLSKey,
+			SelfSigned:     opts.SelfSigned,
+			Players:        opts.Players,
+			Grace:          opts.Grace,
+			SpectatorDelay: opts.SpectatorDelay,
+			BestOf:         opts.BestOf,
+			TurnTime:       opts.TurnTime,
+			OnTimeout:      opts.OnTimeout,
+			Clock:          opts.Clock,
+		}
+		if err := cmd.NewServer(settings, srvOpts, logger, opts.Dbg).Run(); err != nil {
 			panic(err)
 		}
 		return
@@ -47,8 +110,46 @@ func main() {
 
 	if opts.Client {
 		serverAddr := "ws://" + opts.Addr
-		if err := cmd.NewClient(serverAddr, logger, opts.Dbg).Run(); err != nil {
+		if opts.TLS {
+			serverAddr = "wss://" + opts.Addr
+		}
+		client := cmd.NewClient(serverAddr, opts.Insecure, opts.Spectate, opts.Resume, opts.Room, opts.Create, logger, opts.Dbg)
+		if opts.Rooms {
+			if err := client.ListRooms(); err != nil {
+				fmt.Printf("error: %v", err)
+				os.Exit(1)
+			}
+			return
+		}
+		if err := client.Run(); err != nil {
 			panic(err)
 		}
 	}
 }
+
+func gameSettings(opts Opts) (g.Settings, error) {
+	settings := g.Settings{
+		Rows:    opts.Rows,
+		Cols:    opts.Cols,
+		Mines:   opts.Mines,
+		NoGuess: opts.NoGuess,
+	}
+
+	var err error
+	if settings.Difficulty, err = g.ParseDifficulty(opts.Difficulty); err != nil {
+		return settings, err
+	}
+	if settings.Mode, err = g.ParseMode(opts.Mode); err != nil {
+		return settings, err
+	}
+	if settings.Difficulty != g.CUSTOM && (opts.Rows != 0 || opts.Cols != 0 || opts.Mines != 0) {
+		return settings, fmt.Errorf("--rows, --cols and --mines require --difficulty=custom")
+	}
+	if opts.Seed != "" {
+		if settings.Seed, settings.First, err = g.ParseSeed(opts.Seed); err != nil {
+			return settings, err
+		}
+	}
+
+	return settings, settings.Validate()
+}
@@ -0,0 +1,30 @@
+package game
+
+import "time"
+
+// ClockLeft returns the chess clock budget left of the player, the running
+// move of the current player is counted
+func (m *Model) ClockLeft(player string) time.Duration {
+	left := m.Clocks[player]
+	if player == m.CurrentTurn && !m.TurnStarted.IsZero() {
+		left -= m.Now().Sub(m.TurnStarted)
+	}
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// Now returns the current time of the server clock. Deadlines of the model
+// (TurnUntil, PausedUntil, StartsAt, pings) are set by the server, clients
+// count them down by its clock, which may differ from their own.
+func (m *Model) Now() time.Time {
+	return time.Now().Add(m.offset)
+}
+
+// SyncClock sets the server clock by the server time sent with the update
+func (m *Model) SyncClock(serverNow time.Time) {
+	if !serverNow.IsZero() {
+		m.offset = time.Until(serverNow)
+	}
+}
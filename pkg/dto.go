@@ -1,6 +1,9 @@
 package game
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type EventType int
 
@@ -8,11 +11,22 @@ const (
 	NoOp EventType = iota
 	CursorMove
 	OpenCell
+	Chord
+	ToggleFlag
+	ToggleGuess
+	Resync
+	ToggleReady
+	CastVote
+	Rematch
+	Chat
+	PingCell
 )
 
 type Event struct {
 	Type     EventType
 	Position Point
+	Vote     *Vote  `json:",omitempty"` // board the player votes for, CastVote only
+	Text     string `json:",omitempty"` // message of the player, Chat only
 }
 
 func NewEvent(t EventType, pos Point) *Event {
@@ -22,6 +36,16 @@ func NewEvent(t EventType, pos Point) *Event {
 	}
 }
 
+// NewVoteEvent makes an event of the player's vote in the LOBBY
+func NewVoteEvent(v Vote) *Event {
+	return &Event{Type: CastVote, Vote: &v}
+}
+
+// NewChatEvent makes an event of the player's chat message
+func NewChatEvent(text string) *Event {
+	return &Event{Type: Chat, Text: text}
+}
+
 func NewEventFromBytes(bs []byte) *Event {
 	e := new(Event)
 	FromGob(bs, e)
@@ -33,6 +57,18 @@ func (e *Event) String() string {
 		"NoOp",
 		"CursorMove",
 		"OpenCell",
+		"Chord",
+		"ToggleFlag",
+		"ToggleGuess",
+		"Resync",
+		"ToggleReady",
+		"CastVote",
+		"Rematch",
+		"Chat",
+		"PingCell",
+	}
+	if int(e.Type) < 0 || int(e.Type) >= len(titles) {
+		return fmt.Sprintf("[EventType(%d)] %v", int(e.Type), e.Position)
 	}
 	return fmt.Sprintf("[%s] %v", titles[e.Type], e.Position)
 }
@@ -40,3 +76,37 @@ func (e *Event) String() string {
 func (e *Event) Bytes() []byte {
 	return ToGob(*e)
 }
+
+// Cell is a changed cell of the field
+type Cell struct {
+	P Point
+	V rune
+}
+
+// Marker is a changed marker (FLAG, GESS or HIDE for removed) of the player
+type Marker struct {
+	Player string
+	P      Point
+	V      rune
+}
+
+// Delta is a change of the game since the update with Seq-1
+type Delta struct {
+	Seq         uint64
+	Cells       []Cell
+	Markers     []Marker
+	Cursors     map[string]Point // cursors of all players
+	LeftToOpen  int
+	State       int
+	Winner      string
+	CurrentTurn string
+	Players     []string
+	Eliminated  []string
+	Score       map[string]int // mines found by each player, in FLAGS
+	TurnStarted time.Time
+	TurnUntil   time.Time
+	Clocks      map[string]time.Duration
+	Pings       map[string]Ping
+	Guessing    bool      // the NoGuess board turned out to need guessing
+	Now         time.Time // server time when the delta was sent, see Model.SyncClock
+}
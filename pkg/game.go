@@ -2,7 +2,11 @@ package game
 
 import (
 	"fmt"
+	"log"
 	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -12,12 +16,97 @@ const (
 	EASY Difficulty = iota
 	NORMAL
 	HARD
+	CUSTOM
 )
 
+const (
+	MaxRows = 99
+	MaxCols = 99
+)
+
+var difficultyTitles = []string{
+	"EASY",
+	"NORMAL",
+	"HARD",
+	"CUSTOM",
+}
+
+func (d Difficulty) String() string {
+	if int(d) < 0 || int(d) >= len(difficultyTitles) {
+		return fmt.Sprintf("Difficulty(%d)", int(d))
+	}
+	return difficultyTitles[d]
+}
+
+// ParseDifficulty parses difficulty title, case-insensitive
+func ParseDifficulty(s string) (Difficulty, error) {
+	for i, t := range difficultyTitles {
+		if strings.EqualFold(s, t) {
+			return Difficulty(i), nil
+		}
+	}
+	return EASY, fmt.Errorf("unknown difficulty %q", s)
+}
+
+// Settings describes the board to generate
+type Settings struct {
+	Difficulty        Difficulty
+	Rows, Cols, Mines int // used by CUSTOM difficulty only
+	Seed              int64
+	First             *Point // first opened cell of the replayed board, nil to lay it out on the first move
+	NoGuess           bool
+	Mode              Mode
+}
+
+// Size returns the board dimensions and mines count for the difficulty
+func (s Settings) Size() (rows, cols, mines int) {
+	switch s.Difficulty {
+	case NORMAL:
+		return 16, 16, 40
+	case HARD:
+		return 16, 30, 99
+	case CUSTOM:
+		return s.Rows, s.Cols, s.Mines
+	default:
+		return 9, 9, 10
+	}
+}
+
+// Validate checks that the board can be generated
+func (s Settings) Validate() error {
+	if s.Difficulty < EASY || s.Difficulty > CUSTOM {
+		return fmt.Errorf("unknown difficulty %d", s.Difficulty)
+	}
+	if s.Mode < VERSUS || int(s.Mode) >= len(modeTitles) {
+		return fmt.Errorf("unknown mode %d", s.Mode)
+	}
+
+	rows, cols, mines := s.Size()
+	if rows < 1 || rows > MaxRows {
+		return fmt.Errorf("rows should be in [1, %d], got %d", MaxRows, rows)
+	}
+	if cols < 1 || cols > MaxCols {
+		return fmt.Errorf("cols should be in [1, %d], got %d", MaxCols, cols)
+	}
+	// the first opened cell is always safe
+	if mines < 1 || mines > rows*cols-1 {
+		return fmt.Errorf("mines should be in [1, %d] for %dx%d board, got %d", rows*cols-1, rows, cols, mines)
+	}
+	if d := float64(mines) / float64(rows*cols); s.NoGuess && d > MaxNoGuessDensity {
+		return fmt.Errorf("no-guess boards are limited to %.0f%% of mines, got %.0f%%", MaxNoGuessDensity*100, d*100)
+	}
+	if f := s.First; f != nil && (f[0] < 0 || f[0] >= rows || f[1] < 0 || f[1] >= cols) {
+		return fmt.Errorf("first cell %v is out of %dx%d board", *f, rows, cols)
+	}
+	return nil
+}
+
 const (
 	GAME = iota
 	OVER
 	WIN
+	PAUSE // waiting for a disconnected player to come back
+	LOBBY // players get ready and vote for the board before the game
 
 	HIDE  = '~'
 	MINE  = '*'
@@ -32,37 +121,68 @@ const (
 type Game struct {
 	M          *Model
 	Difficulty Difficulty
+	Seed       int64 `json:",string"` // seed of the board generator, the same seed gives the same board
+	First      Point // first opened cell, the board is laid out around it once M.Placed
 
-	dbg bool
+	dbg    bool
+	replay bool       // First is given by the settings, see Opening
+	mu     sync.Mutex // serializes moves of players opening cells at once in COOP
 }
 
-func (g Game) String() string {
-	return fmt.Sprintf("ST: %s, lTo: %d", g.getState(), g.M.LeftToOpen)
+func (g *Game) String() string {
+	return fmt.Sprintf("ST: %s, lTo: %d, seed: %s", g.getState(), g.M.LeftToOpen, g.SeedCode())
 }
 
-func (g *Game) OpenCell(p Point) {
+// OpenCell opens the cell and returns all cells changed by the move. It's
+// safe for players opening cells at once, the cell is opened by the first one.
+func (g *Game) OpenCell(p Point, player string) []Cell {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.openCell(p, player)
+}
+
+func (g *Game) openCell(p Point, player string) []Cell {
 	m := g.M
-	mine := m.Mines[p[0]][p[1]]
-	
+
 	// Skip if already opened
 	if m.Field[p[0]][p[1]] != HIDE && m.Field[p[0]][p[1]] != FLAG && m.Field[p[0]][p[1]] != GESS {
-		return
+		return nil
+	}
+
+	var changed []Cell
+	set := func(r, c int, v rune) {
+		m.Field[r][c] = v
+		changed = append(changed, Cell{P: Point{r, c}, V: v})
 	}
-	
+
+	// first move: generate the board around the opened cell
+	if !m.Placed {
+		g.place(g.Layout(p))
+	}
+	mine := m.Mines[p[0]][p[1]]
+	open := func() {
+		m.LeftToOpen--
+		m.Opened[player]++
+	}
+
 	switch mine {
 	case MINE:
-		g.M.State = OVER
-		// setup all mines and BOOM
-		for r := 0; r < g.M.N; r++ {
-			for c := 0; c < g.M.M; c++ {
-				if g.M.Mines[r][c] == MINE {
-					g.M.Field[r][c] = MINE
-				}
-				if r == p[0] && c == p[1] {
-					g.M.Field[r][c] = BOOM
-				}
-			}
+		if m.Mode == FLAGS {
+			// the player found the mine
+			set(p[0], p[1], MINE)
+			m.Score[player]++
+			changed = append(changed, g.scoreFlags(player)...)
+			break
+		}
+		set(p[0], p[1], BOOM)
+		if m.Mode == COOP {
+			// the whole team loses
+			m.Eliminated = append(m.Eliminated, player)
+			changed = append(changed, g.end()...)
+			break
 		}
+		// the player is out, the game goes on while somebody else is left
+		changed = append(changed, g.eliminate(player)...)
 
 	case ZERO:
 		var openCell func(r, c int)
@@ -72,13 +192,15 @@ func (g *Game) OpenCell(p Point) {
 			}
 
 			if m.Mines[r][c] != ZERO {
-				m.Field[r][c] = m.Mines[r][c]
-				m.LeftToOpen--
+				if m.Field[r][c] == HIDE {
+					set(r, c, m.Mines[r][c])
+					open()
+				}
 				return
 			}
 
-			m.Field[r][c] = EMPTY
-			m.LeftToOpen--
+			set(r, c, EMPTY)
+			open()
 
 			dirs := [][]int{
 				{-1, -1}, {-1, 0}, {-1, 1},
@@ -93,61 +215,386 @@ func (g *Game) OpenCell(p Point) {
 			}
 		}
 		openCell(p[0], p[1])
-		
+
 	default:
 		// Numbered cell (1-8)
-		m.Field[p[0]][p[1]] = mine
-		m.LeftToOpen--
+		set(p[0], p[1], mine)
+		open()
 	}
-	
-	// Check for WIN condition after opening any cell
-	if m.LeftToOpen == 0 && m.State != OVER {
+
+	// Check for WIN condition after opening any cell, in COOP the team wins.
+	// In FLAGS the game is about mines.
+	if m.LeftToOpen == 0 && m.State != OVER && m.Mode != FLAGS {
 		m.State = WIN
+		if m.Mode != COOP {
+			m.Winner = player
+		}
 		// Reveal all cells for win screen
 		for r := 0; r < m.N; r++ {
 			for c := 0; c < m.M; c++ {
 				if m.Field[r][c] == HIDE || m.Field[r][c] == FLAG || m.Field[r][c] == GESS {
-					m.Field[r][c] = m.Mines[r][c]
+					set(r, c, m.Mines[r][c])
 				}
 			}
 		}
 	}
+
+	return changed
+}
+
+// scoreFlags ends the FLAGS game once the player has found more than half of
+// the mines. If all mines are found first, the best score wins or it's a draw.
+func (g *Game) scoreFlags(player string) []Cell {
+	m := g.M
+	found, best, winner := 0, 0, ""
+	for id, n := range m.Score {
+		found += n
+		switch {
+		case n > best:
+			best, winner = n, id
+		case n == best:
+			winner = ""
+		}
+	}
+	if m.Score[player]*2 > m.MinesCount {
+		winner = player
+	} else if found < m.MinesCount {
+		return nil
+	}
+
+	changed := g.end()
+	if winner != "" {
+		m.State = WIN
+		m.Winner = winner
+	}
+	return changed
+}
+
+// Chord opens all not flagged hidden neighbours of an opened number if the
+// count of player's flags around equals to the number. A wrong flag means one
+// of opened neighbours is a mine and detonates it. Returns all changed cells,
+// nothing if the number isn't satisfied.
+func (g *Game) Chord(p Point, player string) []Cell {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	m := g.M
+	v := m.Field[p[0]][p[1]]
+	// in FLAGS a move opens one cell
+	if v <= ZERO || v > '8' || m.Mode == FLAGS {
+		return nil
+	}
+
+	var toOpen []Point
+	flagsCount := 0
+	for _, n := range m.Neighbours(p) {
+		// mines detonated by eliminated players are known
+		if m.Field[n[0]][n[1]] == BOOM {
+			flagsCount++
+			continue
+		}
+		if m.Field[n[0]][n[1]] != HIDE {
+			continue
+		}
+		if m.Mark(player, n) == FLAG {
+			flagsCount++
+		} else {
+			toOpen = append(toOpen, n)
+		}
+	}
+	if flagsCount != int(v-ZERO) {
+		return nil
+	}
+
+	var changed []Cell
+	for _, n := range toOpen {
+		if m.State != GAME || m.IsEliminated(player) {
+			break
+		}
+		changed = append(changed, g.openCell(n, player)...)
+	}
+	return changed
+}
+
+// Eliminate puts the player out of the game. The game is over when less than
+// two players are left, the last one wins. In COOP the team plays on while
+// anybody is left. Returns mines revealed at the end.
+func (g *Game) Eliminate(player string) []Cell {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.eliminate(player)
+}
+
+func (g *Game) eliminate(player string) []Cell {
+	m := g.M
+	if m.IsOver() || m.IsEliminated(player) {
+		return nil
+	}
+	m.Eliminated = append(m.Eliminated, player)
+
+	active := m.Active()
+	if len(active) > 1 || (m.Mode == COOP && len(active) == 1) {
+		return nil
+	}
+
+	if len(active) == 1 {
+		m.Winner = active[0]
+	}
+	return g.end()
+}
+
+// end ends the game with OVER and reveals all mines
+func (g *Game) end() []Cell {
+	m := g.M
+	m.State = OVER
+	m.Paused = ""
+
+	// setup all mines
+	var changed []Cell
+	for r := 0; r < m.N; r++ {
+		for c := 0; c < m.M; c++ {
+			if m.Mines[r][c] == MINE && m.Field[r][c] != BOOM {
+				m.Field[r][c] = MINE
+				changed = append(changed, Cell{P: Point{r, c}, V: MINE})
+			}
+		}
+	}
+	return changed
+}
+
+// ToggleFlag sets or removes player's FLAG marker on a hidden cell
+func (g *Game) ToggleFlag(player string, p Point) []Marker {
+	return g.toggleMark(player, p, FLAG)
+}
+
+// ToggleGuess sets or removes player's GESS marker on a hidden cell
+func (g *Game) ToggleGuess(player string, p Point) []Marker {
+	return g.toggleMark(player, p, GESS)
 }
 
-func (g *Game) Bytes() []byte {
-	return ToGob(g)
+func (g *Game) toggleMark(player string, p Point, mark rune) []Marker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	m := g.M
+	if m.State != GAME || m.Field[p[0]][p[1]] != HIDE {
+		return nil
+	}
+
+	layer := m.layer(player)
+	if layer[p[0]][p[1]] == mark {
+		layer[p[0]][p[1]] = HIDE
+	} else {
+		layer[p[0]][p[1]] = mark
+	}
+	return []Marker{{Player: player, P: p, V: layer[p[0]][p[1]]}}
+}
+
+// Delta makes an update of changed cells and markers with the current state
+func (g *Game) Delta(cells []Cell, markers []Marker) *Delta {
+	return &Delta{
+		Cells:       cells,
+		Markers:     markers,
+		Cursors:     g.M.Cursors,
+		LeftToOpen:  g.M.LeftToOpen,
+		State:       g.M.State,
+		Winner:      g.M.Winner,
+		CurrentTurn: g.M.CurrentTurn,
+		Players:     g.M.Players,
+		Eliminated:  g.M.Eliminated,
+		Score:       g.M.Score,
+		TurnStarted: g.M.TurnStarted,
+		TurnUntil:   g.M.TurnUntil,
+		Clocks:      g.M.Clocks,
+		Pings:       g.M.Pings,
+		Guessing:    g.M.Guessing,
+	}
+}
+
+// Copy returns a deep copy of the model for readers which can't hold the lock
+// of the game while they use it
+func (m *Model) Copy() *Model {
+	c := *m
+	c.Field = copyField(m.Field)
+	c.Mines = copyField(m.Mines)
+	c.Marks = make(map[string][][]rune, len(m.Marks))
+	for id, layer := range m.Marks {
+		c.Marks[id] = copyField(layer)
+	}
+	c.Cursors = copyMap(m.Cursors)
+	c.Players = append([]string(nil), m.Players...)
+	c.Eliminated = append([]string(nil), m.Eliminated...)
+	c.Ready = copyMap(m.Ready)
+	c.Votes = copyMap(m.Votes)
+	c.Opened = copyMap(m.Opened)
+	c.Progress = copyMap(m.Progress)
+	c.Score = copyMap(m.Score)
+	c.Clocks = copyMap(m.Clocks)
+	c.Pings = copyMap(m.Pings)
+	if m.Series != nil {
+		series := *m.Series
+		series.Score = copyMap(m.Series.Score)
+		series.Rematch = copyMap(m.Series.Rematch)
+		c.Series = &series
+	}
+	return &c
+}
+
+// View returns the game as players see it. While the game is in progress the
+// mine layout and the seed (which gives the layout away) are hidden.
+func (g *Game) View() *Game {
+	if g.M.IsOver() {
+		return g
+	}
+
+	m := *g.M
+	m.Mines = nil
+	return &Game{
+		M:          &m,
+		Difficulty: g.Difficulty,
+		dbg:        g.dbg,
+	}
 }
 
 func (g *Game) getState() string {
+	return StateTitle(g.M.State)
+}
+
+// StateTitle returns the name of the game state
+func StateTitle(state int) string {
 	statesTitles := []string{
 		"GAME",
 		"OVER",
 		"WIN",
+		"PAUSE",
+		"LOBBY",
+	}
+	if state < 0 || state >= len(statesTitles) {
+		return fmt.Sprintf("State(%d)", state)
 	}
-	return statesTitles[g.M.State]
+	return statesTitles[state]
 }
 
 func (g *Game) getModel() Model {
 	return *g.M
 }
 
-func NewGame(difficulty Difficulty, dbg bool) *Game {
-	var m Model
-
-	switch difficulty {
-	case EASY:
-		m = NewModel(9, 9, 10, dbg)
-	case NORMAL:
-		m = NewModel(16, 16, 40, dbg)
-	case HARD:
-		m = NewModel(16, 30, 99, dbg)
+// NewGame creates a new game with valid settings. If seed is 0 a random one is
+// used. The board of a replayed seed is laid out around its first cell before
+// anybody opens a cell, see Opening.
+func NewGame(s Settings, dbg bool) *Game {
+	if s.Seed == 0 {
+		s.Seed = time.Now().UnixNano()
 	}
 
-	return &Game{
+	rows, cols, mines := s.Size()
+	m := NewModel(rows, cols, mines, s.Seed, s.NoGuess, dbg)
+	m.Mode = s.Mode
+
+	g := &Game{
 		M:          &m,
-		Difficulty: difficulty,
+		Difficulty: s.Difficulty,
+		Seed:       s.Seed,
 		dbg:        dbg,
 	}
+	// the cell may be out of another board voted for in the lobby
+	if s.First != nil && m.Inside(*s.First) {
+		g.First = *s.First
+		g.replay = true
+	}
+	return g
+}
+
+// Opening returns the cell to lay the board out around before anybody opens a
+// cell: the first cell of a replayed board, or the center in RACE, where it's
+// opened for everybody. Other boards wait for the first move.
+func (g *Game) Opening() (Point, bool) {
+	switch {
+	case g.replay:
+		return g.First, true
+	case g.M.Mode == RACE:
+		return Point{g.M.N / 2, g.M.M / 2}, true
+	}
+	return Point{}, false
+}
+
+// Layout is the mine layout of the board generated for the first opened cell
+type Layout struct {
+	first      Point
+	mines      [][]rune
+	leftToOpen int
+	guessing   bool
+}
+
+// Layout generates the mine layout for the first opened cell from the seed.
+// It doesn't change the game, so the long search for a no-guess board may run
+// without holding locks, Place lays the mines out then.
+func (g *Game) Layout(first Point) *Layout {
+	m := NewModel(g.M.N, g.M.M, g.M.MinesCount, g.Seed, g.M.NoGuess, g.dbg)
+	m.placeMines(first)
+	return &Layout{first: first, mines: m.Mines, leftToOpen: m.LeftToOpen, guessing: m.Guessing}
+}
+
+// Place lays the mines out unless the board is laid out already, e.g. by the
+// first move of another player
+func (g *Game) Place(l *Layout) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.place(l)
+}
+
+func (g *Game) place(l *Layout) {
+	m := g.M
+	if m.Placed {
+		return
+	}
+	m.Mines, m.LeftToOpen, m.Guessing, m.Placed = l.mines, l.leftToOpen, l.guessing, true
+	g.First = l.first
+}
+
+// Title describes the board, e.g. "EASY 9x9, 10 mines, no guess"
+func (g *Game) Title() string {
+	title := fmt.Sprintf("%s %dx%d, %d mines", g.Difficulty, g.M.N, g.M.M, g.M.MinesCount)
+	switch {
+	case g.M.Guessing:
+		title += ", guessing may be required"
+	case g.M.NoGuess:
+		title += ", no guess"
+	}
+	return title
+}
+
+// SeedCode returns the seed as a short shareable code. The board depends on
+// the first opened cell too, once it's laid out the code ends with the cell,
+// e.g. "K2J9XQ-4-4".
+func (g *Game) SeedCode() string {
+	code := strings.ToUpper(strconv.FormatInt(g.Seed, 36))
+	if g.M.Placed {
+		code += fmt.Sprintf("-%d-%d", g.First[0], g.First[1])
+	}
+	return code
+}
+
+// ParseSeed parses a seed code made by Game.SeedCode. The first cell is nil if
+// the code has none.
+func ParseSeed(code string) (int64, *Point, error) {
+	var first *Point
+	// the seed itself may be negative, the cell is at the end
+	if parts := strings.Split(code, "-"); len(parts) >= 3 {
+		r, errR := strconv.Atoi(parts[len(parts)-2])
+		c, errC := strconv.Atoi(parts[len(parts)-1])
+		if errR != nil || errC != nil {
+			return 0, nil, fmt.Errorf("invalid first cell in seed code %q", code)
+		}
+		first = &Point{r, c}
+		code = strings.Join(parts[:len(parts)-2], "-")
+	}
+
+	seed, err := strconv.ParseInt(strings.ToLower(code), 36, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid seed code %q: %w", code, err)
+	}
+	return seed, first, nil
 }
 
 type Point [2]int
@@ -171,11 +618,37 @@ type Model struct {
 	State        int
 	Winner       string // ID of the winning player (e.g., "P1", "P2")
 	CurrentTurn  string // ID of player whose turn it is (e.g., "P1", "P2")
+	MinesCount   int
+	Placed       bool                     // true after Mines were generated by the first OpenCell
+	Marks        map[string][][]rune      // FLAG and GESS markers layer of each player ID
+	Cursors      map[string]Point         // cursor of each player ID
+	NoGuess      bool                     // generate only boards solvable without guessing
+	Guessing     bool                     // no NoGuess board was found, guessing may be required
+	Paused       string                   // reason of the PAUSE, e.g. "P2 is reconnecting"
+	PausedUntil  time.Time                // the game ends if the PAUSE isn't resolved until then
+	Players      []string                 // IDs of joined players in turn order
+	Eliminated   []string                 // IDs of players out of the game after a mine hit
+	Ready        map[string]bool          // players ready to start the game, in LOBBY
+	Votes        map[string]Vote          // board each player votes for, in LOBBY
+	Choice       Vote                     // board chosen by the votes
+	StartsAt     time.Time                // end of the countdown once everybody is ready
+	Series       *Series                  // scoreboard of the games played in the room
+	Mode         Mode                     // how players play the board together
+	Opened       map[string]int           // cells opened by each player ID, their contribution
+	Progress     map[string]int           // cells left to open on the private board of each player ID, in RACE
+	Score        map[string]int           // mines found by each player ID, in FLAGS
+	TurnStarted  time.Time                // when the clock of the current move started
+	TurnUntil    time.Time                // the current move times out then, zero without a time limit
+	Clocks       map[string]time.Duration // chess clock budget left of each player ID before the current move
+	Pings        map[string]Ping          // the last cell pinged by each player ID
 
 	Dbg bool
+
+	rnd    *rand.Rand
+	offset time.Duration // of the server clock from the local one, see Now
 }
 
-func NewModel(n, m, minesCount int, dbg bool) Model {
+func NewModel(n, m, minesCount int, seed int64, noGuess, dbg bool) Model {
 	var field, mines [][]rune
 	field = make([][]rune, n)
 	mines = make([][]rune, n)
@@ -184,38 +657,104 @@ func NewModel(n, m, minesCount int, dbg bool) Model {
 		field[i] = make([]rune, m)
 		mines[i] = make([]rune, m)
 		for j := 0; j < m; j++ {
-			mines[i][j] = '0'
+			mines[i][j] = ZERO
 		}
 		for j := 0; j < m; j++ {
 			field[i][j] = HIDE
 		}
 	}
 
-	// setup Mines
-	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
-	for minesCount > 0 {
-		r, c := rnd.Intn(n), rnd.Intn(m)
-		if mines[r][c] != MINE {
-			mines[r][c] = MINE
-			minesCount--
+	// Mines are placed lazily on the first OpenCell (see placeMines),
+	// so the first opened cell is always safe
+	return Model{
+		Field:      field,
+		Mines:      mines,
+		MinesCount: minesCount,
+		Marks:      make(map[string][][]rune),
+		Cursors:    make(map[string]Point),
+		Opened:     make(map[string]int),
+		Score:      make(map[string]int),
+		NoGuess:    noGuess,
+		LeftToOpen: n*m - minesCount,
+		N:          n,
+		M:          m,
+		Dbg:        dbg,
+
+		rnd: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// placeMines generates the board for the first opened cell. In NoGuess mode
+// boards are regenerated until the solver can open them without guessing.
+func (m *Model) placeMines(first Point) {
+	m.layMines(first)
+	if !m.NoGuess {
+		return
+	}
+
+	work := noGuessWork
+	for i := 1; ; i++ {
+		solved, done := solve(m, first, work)
+		if solved {
+			return
 		}
+		work -= done
+		if i == maxNoGuessAttempts || work <= 0 {
+			break
+		}
+		m.layMines(first)
 	}
-	// count Mines
-	dirs := [][]int{
-		{-1, -1}, {-1, 0}, {-1, 1},
-		{0, -1}, {0, 1},
-		{1, -1}, {1, 0}, {1, 1},
+	log.Printf("can't generate no-guess board within %d attempts and the work limit, guessing may be required", maxNoGuessAttempts)
+	m.Guessing = true
+}
+
+// layMines scatters MinesCount mines avoiding the first opened cell and
+// its neighbours, then counts numbers and cells left to open
+func (m *Model) layMines(first Point) {
+	for r := range m.Mines {
+		for c := range m.Mines[r] {
+			m.Mines[r][c] = ZERO
+		}
 	}
 
-	for r := 0; r < n; r++ {
-		for c := 0; c < m; c++ {
-			if mines[r][c] == MINE {
-				for _, d := range dirs {
-					newR, newC := r+d[0], c+d[1]
-					if newR >= 0 && newR < n && newC >= 0 && newC < m {
-						if mines[newR][newC] != MINE {
-							mines[newR][newC]++
-						}
+	isSafe := func(r, c int) bool {
+		dr, dc := r-first[0], c-first[1]
+		return dr >= -1 && dr <= 1 && dc >= -1 && dc <= 1
+	}
+
+	var candidates []Point
+	for r := 0; r < m.N; r++ {
+		for c := 0; c < m.M; c++ {
+			if !isSafe(r, c) {
+				candidates = append(candidates, Point{r, c})
+			}
+		}
+	}
+	// too dense board to keep the whole 3x3 area clear, keep at least the cell itself
+	if len(candidates) < m.MinesCount {
+		candidates = candidates[:0]
+		for r := 0; r < m.N; r++ {
+			for c := 0; c < m.M; c++ {
+				if r != first[0] || c != first[1] {
+					candidates = append(candidates, Point{r, c})
+				}
+			}
+		}
+	}
+
+	// setup Mines
+	for _, i := range m.rnd.Perm(len(candidates))[:m.MinesCount] {
+		p := candidates[i]
+		m.Mines[p[0]][p[1]] = MINE
+	}
+
+	// count Mines
+	for r := 0; r < m.N; r++ {
+		for c := 0; c < m.M; c++ {
+			if m.Mines[r][c] == MINE {
+				for _, n := range m.Neighbours(Point{r, c}) {
+					if m.Mines[n[0]][n[1]] != MINE {
+						m.Mines[n[0]][n[1]]++
 					}
 				}
 			}
@@ -224,20 +763,133 @@ func NewModel(n, m, minesCount int, dbg bool) Model {
 
 	// count shouldOpen (all non-mine cells)
 	var shouldOpen int
-	for _, r := range mines {
+	for _, r := range m.Mines {
 		for _, c := range r {
 			if c != MINE {
 				shouldOpen++
 			}
 		}
 	}
+	m.LeftToOpen = shouldOpen
+	m.Placed = true
+}
 
-	return Model{
-		Field:      field,
-		Mines:      mines,
-		LeftToOpen: shouldOpen,
-		N:          n,
-		M:          m,
-		Dbg:        dbg,
+// layer returns markers layer of the player, creating an empty one if needed
+func (m *Model) layer(player string) [][]rune {
+	layer, ok := m.Marks[player]
+	if !ok {
+		layer = make([][]rune, m.N)
+		for r := range layer {
+			layer[r] = make([]rune, m.M)
+			for c := range layer[r] {
+				layer[r][c] = HIDE
+			}
+		}
+		m.Marks[player] = layer
+	}
+	return layer
+}
+
+// Apply applies server delta to the model
+func (m *Model) Apply(d *Delta) {
+	for _, c := range d.Cells {
+		m.Field[c.P[0]][c.P[1]] = c.V
+	}
+	if m.Marks == nil {
+		m.Marks = make(map[string][][]rune)
+	}
+	for _, mk := range d.Markers {
+		m.layer(mk.Player)[mk.P[0]][mk.P[1]] = mk.V
+	}
+	m.Cursors = d.Cursors
+	m.LeftToOpen = d.LeftToOpen
+	m.State = d.State
+	m.Winner = d.Winner
+	m.CurrentTurn = d.CurrentTurn
+	m.Players = d.Players
+	m.Eliminated = d.Eliminated
+	m.Score = d.Score
+	m.TurnStarted = d.TurnStarted
+	m.TurnUntil = d.TurnUntil
+	m.Clocks = d.Clocks
+	m.Pings = d.Pings
+	m.Guessing = d.Guessing
+	m.SyncClock(d.Now)
+}
+
+// Mark returns player's marker (FLAG or GESS) on the cell or HIDE if there is
+// no marker. Markers on opened cells are ignored.
+func (m *Model) Mark(player string, p Point) rune {
+	layer, ok := m.Marks[player]
+	if !ok || m.Field[p[0]][p[1]] != HIDE {
+		return HIDE
+	}
+	return layer[p[0]][p[1]]
+}
+
+// IsEliminated reports whether the player is out of the game
+func (m *Model) IsEliminated(player string) bool {
+	for _, id := range m.Eliminated {
+		if id == player {
+			return true
+		}
+	}
+	return false
+}
+
+// Active returns players who are still in the game, in turn order
+func (m *Model) Active() []string {
+	var active []string
+	for _, id := range m.Players {
+		if !m.IsEliminated(id) {
+			active = append(active, id)
+		}
+	}
+	return active
+}
+
+// NextPlayer returns the active player whose turn is after the player, it's
+// the player itself if nobody else is left. Empty if nobody is active.
+func (m *Model) NextPlayer(player string) string {
+	i := 0
+	for ; i < len(m.Players); i++ {
+		if m.Players[i] == player {
+			break
+		}
+	}
+	for j := 1; j <= len(m.Players); j++ {
+		id := m.Players[(i+j)%len(m.Players)]
+		if !m.IsEliminated(id) {
+			return id
+		}
+	}
+	return ""
+}
+
+// IsOver reports whether the game has ended
+func (m *Model) IsOver() bool {
+	return m.State == OVER || m.State == WIN
+}
+
+// Inside reports whether p is a cell of the field
+func (m *Model) Inside(p Point) bool {
+	return p[0] >= 0 && p[0] < m.N && p[1] >= 0 && p[1] < m.M
+}
+
+// Neighbours returns up to 8 cells around p that are inside the field
+func (m *Model) Neighbours(p Point) []Point {
+	dirs := [][]int{
+		{-1, -1}, {-1, 0}, {-1, 1},
+		{0, -1}, {0, 1},
+		{1, -1}, {1, 0}, {1, 1},
+	}
+
+	var ns []Point
+	for _, d := range dirs {
+		newR, newC := p[0]+d[0], p[1]+d[1]
+		if newR >= 0 && newR < m.N && newC >= 0 && newC < m.M {
+			ns = append(ns, Point{newR, newC})
+		}
 	}
+	return ns
 }
@@ -0,0 +1,60 @@
+package game
+
+// Vote is the board and the mode a player votes for in the LOBBY
+type Vote struct {
+	Difficulty Difficulty
+	NoGuess    bool
+	Mode       Mode
+}
+
+func (v Vote) String() string {
+	s := v.Difficulty.String()
+	if v.NoGuess {
+		s += ", no guess"
+	}
+	return s + ", " + v.Mode.String()
+}
+
+// Tally returns the board chosen by votes: the difficulty and the mode with
+// the most votes and no-guess if more than half of voters want it. Ties are
+// resolved in favour of the default, then of the easier difficulty and the
+// first mode.
+func Tally(votes map[string]Vote, def Vote) Vote {
+	counts := make(map[Difficulty]int)
+	modes := make(map[Mode]int)
+	yes := 0
+	for _, v := range votes {
+		counts[v.Difficulty]++
+		modes[v.Mode]++
+		if v.NoGuess {
+			yes++
+		}
+	}
+
+	choice := def
+	for d := EASY; d <= CUSTOM; d++ {
+		if counts[d] > counts[choice.Difficulty] {
+			choice.Difficulty = d
+		}
+	}
+
+	for _, mode := range Modes() {
+		if modes[mode] > modes[choice.Mode] {
+			choice.Mode = mode
+		}
+	}
+
+	no := len(votes) - yes
+	choice.NoGuess = yes > no || (yes == no && def.NoGuess)
+	return choice
+}
+
+// IsReady reports whether all players are ready to start the game
+func (m *Model) IsReady() bool {
+	for _, id := range m.Players {
+		if !m.Ready[id] {
+			return false
+		}
+	}
+	return len(m.Players) > 0
+}
@@ -1,20 +1,28 @@
 package game
 
+import "sync"
+
 type Logger struct {
 	rows *[]string
+	mu   *sync.Mutex // guards rows, UIs read them while others log
 }
 
 func NewLogger() Logger {
 	return Logger{
 		rows: new([]string),
+		mu:   new(sync.Mutex),
 	}
 }
 
 func (l Logger) Write(p []byte) (n int, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	*l.rows = append(*l.rows, string(p))
 	return len(p), nil
 }
 
 func (l Logger) GetLogs() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	return *l.rows
 }
@@ -0,0 +1,54 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode is the way players play the board together
+type Mode int
+
+const (
+	VERSUS Mode = iota // players take turns, a mine puts the player out of the game
+	COOP               // everybody opens cells at any time, the team wins or loses together
+	RACE               // everybody plays a private copy of the same board, the first to clear it wins
+	FLAGS              // players take turns finding mines, a found mine scores and the player goes again
+)
+
+var modeTitles = []string{
+	"VERSUS",
+	"COOP",
+	"RACE",
+	"FLAGS",
+}
+
+func (m Mode) String() string {
+	if int(m) < 0 || int(m) >= len(modeTitles) {
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+	return modeTitles[m]
+}
+
+// ParseMode parses mode title, case-insensitive
+func ParseMode(s string) (Mode, error) {
+	for i, t := range modeTitles {
+		if strings.EqualFold(s, t) {
+			return Mode(i), nil
+		}
+	}
+	return VERSUS, fmt.Errorf("unknown mode %q", s)
+}
+
+// Modes returns all game modes
+func Modes() []Mode {
+	modes := make([]Mode, len(modeTitles))
+	for i := range modes {
+		modes[i] = Mode(i)
+	}
+	return modes
+}
+
+// HasTurns reports whether players take turns in the mode
+func (m Mode) HasTurns() bool {
+	return m == VERSUS || m == FLAGS
+}
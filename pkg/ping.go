@@ -0,0 +1,26 @@
+package game
+
+import "time"
+
+// PingTime is how long the pinged cell is highlighted
+const PingTime = 3 * time.Second
+
+// Ping is a cell the player points other players at
+type Ping struct {
+	P     Point
+	Until time.Time // the cell is highlighted until then
+}
+
+// PingedBy returns the player pinging the cell at the moment, the latest ping
+// wins, or "" if nobody does
+func (m *Model) PingedBy(p Point) string {
+	now := m.Now()
+	var by string
+	var until time.Time
+	for id, ping := range m.Pings {
+		if ping.P == p && now.Before(ping.Until) && ping.Until.After(until) {
+			by, until = id, ping.Until
+		}
+	}
+	return by
+}
@@ -0,0 +1,269 @@
+package game
+
+import (
+	"fmt"
+	"time"
+)
+
+// ProtocolVersion should be bumped on every incompatible change of messages
+const ProtocolVersion = 1
+
+// Capabilities are optional protocol features a peer supports
+const (
+	CapDelta   = "delta"   // receives Delta messages instead of full State on every change
+	CapChord   = "chord"   // sends Chord events
+	CapMarkers = "markers" // sends ToggleFlag/ToggleGuess events and renders Marks
+)
+
+// Capabilities supported by this build
+var Capabilities = []string{CapDelta, CapChord, CapMarkers}
+
+type MsgType int
+
+const (
+	MsgHello MsgType = iota + 1
+	MsgWelcome
+	MsgError
+	MsgState
+	MsgDelta
+	MsgEvent
+	MsgRooms
+	MsgChat
+)
+
+func (t MsgType) String() string {
+	titles := []string{
+		"Unknown",
+		"Hello",
+		"Welcome",
+		"Error",
+		"State",
+		"Delta",
+		"Event",
+		"Rooms",
+		"Chat",
+	}
+	if int(t) < 0 || int(t) >= len(titles) {
+		return titles[0]
+	}
+	return titles[t]
+}
+
+// Error codes
+const (
+	ErrVersion      = "VERSION_MISMATCH"
+	ErrLobbyFull    = "LOBBY_FULL"
+	ErrBadRequest   = "BAD_REQUEST"
+	ErrNoRoom       = "ROOM_NOT_FOUND"
+	ErrSessionInUse = "SESSION_IN_USE" // the player of the token is online, it may be a half-open connection
+)
+
+// Message is an envelope of every frame between the client and the server.
+// Only the field matching Type is set.
+type Message struct {
+	Type    MsgType
+	Hello   *Hello   `json:",omitempty"`
+	Welcome *Welcome `json:",omitempty"`
+	Error   *Error   `json:",omitempty"`
+	State   *State   `json:",omitempty"`
+	Delta   *Delta   `json:",omitempty"`
+	Event   *Event   `json:",omitempty"`
+	Rooms   *Rooms   `json:",omitempty"`
+	Chat    *ChatLog `json:",omitempty"`
+}
+
+// Codec is an encoding of messages, chosen by the client for the connection
+type Codec int
+
+const (
+	Gob  Codec = iota // binary frames, used by the Go client
+	JSON              // text frames, for scripts and the browser
+)
+
+func (c Codec) String() string {
+	if c == JSON {
+		return "json"
+	}
+	return "gob"
+}
+
+// Encode encodes the message
+func (c Codec) Encode(m *Message) []byte {
+	if c == JSON {
+		return ToJSON(*m)
+	}
+	return ToGob(*m)
+}
+
+// Decode decodes the message and checks that its payload is set
+func (c Codec) Decode(bs []byte) (*Message, error) {
+	m := new(Message)
+	var err error
+	if c == JSON {
+		err = FromJSON(bs, m)
+	} else {
+		err = FromGob(bs, m)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Hello is the first message of the client
+type Hello struct {
+	Version      int
+	Capabilities []string
+	Token        string // session token from the previous Welcome, to reclaim the slot
+	Spectate     bool   // join as a spectator even if there is a free slot
+	Room         string // code of the room to join, any open room if empty
+	Create       bool   // create a new room instead of joining an open one
+}
+
+// Welcome is the server reply to Hello for the joined player
+type Welcome struct {
+	Version      int
+	Room         string // code of the joined room, others join the game with it
+	PlayerID     string
+	Token        string   // session token to present in Hello on reconnect
+	Capabilities []string // capabilities supported by both sides
+	Spectator    bool     // the client only watches the game, its events are ignored
+}
+
+// Error is sent by the server on a rejected request
+type Error struct {
+	Code string
+	Text string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Text)
+}
+
+// Rooms lists open rooms of the server. The client may send an empty Rooms
+// message before Hello, the server replies with the list.
+type Rooms struct {
+	List []RoomInfo
+}
+
+// RoomInfo describes a room in the list
+type RoomInfo struct {
+	Code       string
+	Board      string // title of the board, e.g. "EASY 9x9, 10 mines"
+	Players    int
+	MaxPlayers int
+	Spectators int
+	State      int
+}
+
+// ChatLog is a batch of chat messages relayed by the server: one new message or
+// the recent history for the joined client
+type ChatLog struct {
+	Messages []ChatMessage
+}
+
+// ChatMessage is a message of the player in the room chat
+type ChatMessage struct {
+	From string // ID of the sender
+	Text string
+	At   time.Time // when the server got the message
+}
+
+// State is a full snapshot of the game. Following deltas continue from Seq.
+type State struct {
+	Seq  uint64
+	Game *Game
+	Now  time.Time // server time when the state was sent, see Model.SyncClock
+}
+
+func NewHello(token string, spectate bool) *Message {
+	return &Message{Type: MsgHello, Hello: &Hello{Version: ProtocolVersion, Capabilities: Capabilities, Token: token, Spectate: spectate}}
+}
+
+func NewWelcome(room, playerID, token string, caps []string, spectator bool) *Message {
+	return &Message{Type: MsgWelcome, Welcome: &Welcome{Version: ProtocolVersion, Room: room, PlayerID: playerID, Token: token, Capabilities: caps, Spectator: spectator}}
+}
+
+func NewRoomsMessage(list []RoomInfo) *Message {
+	return &Message{Type: MsgRooms, Rooms: &Rooms{List: list}}
+}
+
+func NewChatMessage(msgs ...ChatMessage) *Message {
+	return &Message{Type: MsgChat, Chat: &ChatLog{Messages: msgs}}
+}
+
+func NewError(code, format string, args ...any) *Message {
+	return &Message{Type: MsgError, Error: &Error{Code: code, Text: fmt.Sprintf(format, args...)}}
+}
+
+func NewStateMessage(seq uint64, game *Game) *Message {
+	return &Message{Type: MsgState, State: &State{Seq: seq, Game: game, Now: time.Now()}}
+}
+
+func NewDeltaMessage(d *Delta) *Message {
+	d.Now = time.Now()
+	return &Message{Type: MsgDelta, Delta: d}
+}
+
+func NewEventMessage(e *Event) *Message {
+	return &Message{Type: MsgEvent, Event: e}
+}
+
+// NewMessageFromBytes decodes the gob message and checks that its payload is set
+func NewMessageFromBytes(bs []byte) (*Message, error) {
+	return Gob.Decode(bs)
+}
+
+func (m *Message) validate() error {
+	var ok bool
+	switch m.Type {
+	case MsgHello:
+		ok = m.Hello != nil
+	case MsgWelcome:
+		ok = m.Welcome != nil
+	case MsgError:
+		ok = m.Error != nil
+	case MsgState:
+		ok = m.State != nil && m.State.Game != nil && m.State.Game.M != nil
+	case MsgDelta:
+		ok = m.Delta != nil
+	case MsgEvent:
+		ok = m.Event != nil
+	case MsgRooms:
+		ok = m.Rooms != nil
+	case MsgChat:
+		ok = m.Chat != nil
+	}
+	if !ok {
+		return fmt.Errorf("malformed %s message", m.Type)
+	}
+	return nil
+}
+
+func (m *Message) Bytes() []byte {
+	return Gob.Encode(m)
+}
+
+// HasCapability reports whether caps contains the capability
+func HasCapability(caps []string, c string) bool {
+	for _, x := range caps {
+		if x == c {
+			return true
+		}
+	}
+	return false
+}
+
+// CommonCapabilities returns capabilities of this build also supported by the peer
+func CommonCapabilities(peer []string) []string {
+	var caps []string
+	for _, c := range Capabilities {
+		if HasCapability(peer, c) {
+			caps = append(caps, c)
+		}
+	}
+	return caps
+}
@@ -0,0 +1,69 @@
+package game
+
+// RaceBoards opens the board at its opening and makes a private copy of it for
+// each player, so everybody races on the same minefield from the same opening
+func (g *Game) RaceBoards(players []string) map[string]*Game {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	m := g.M
+	first, _ := g.Opening()
+	g.openCell(first, "")
+	delete(m.Opened, "")
+
+	boards := make(map[string]*Game, len(players))
+	for _, id := range players {
+		boards[id] = g.clone()
+	}
+	return boards
+}
+
+// clone copies the board without players, markers and cursors
+func (g *Game) clone() *Game {
+	m := *g.M
+	m.Field = copyField(g.M.Field)
+	m.Mines = copyField(g.M.Mines)
+	m.Marks = make(map[string][][]rune)
+	m.Cursors = make(map[string]Point)
+	m.Opened = make(map[string]int)
+	m.Players = nil
+	m.Eliminated = nil
+	m.Series = nil
+	return &Game{
+		M:          &m,
+		Difficulty: g.Difficulty,
+		Seed:       g.Seed,
+		First:      g.First,
+		dbg:        g.dbg,
+	}
+}
+
+func copyField(f [][]rune) [][]rune {
+	c := make([][]rune, len(f))
+	for i := range f {
+		c[i] = append([]rune(nil), f[i]...)
+	}
+	return c
+}
+
+// RaceView returns the private board as its player sees it while others may
+// still race on the same minefield: the mine layout revealed by the end of the
+// board is hidden until the match is over
+func (g *Game) RaceView(matchOver bool) *Game {
+	v := g.View()
+	if matchOver || !g.M.IsOver() {
+		return v
+	}
+
+	m := *v.M
+	m.Mines = nil
+	m.Field = copyField(m.Field)
+	for _, row := range m.Field {
+		for c, cell := range row {
+			if cell == MINE {
+				row[c] = HIDE
+			}
+		}
+	}
+	return &Game{M: &m, Difficulty: g.Difficulty, dbg: g.dbg}
+}
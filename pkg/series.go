@@ -0,0 +1,115 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Series is a match of several games in a room, the scoreboard is kept
+// between rematches
+type Series struct {
+	BestOf  int             // games in the series, 0 for an endless one
+	Round   int             // number of the current game, from 1
+	Score   map[string]int  // games won by each player ID
+	Winner  string          // ID of the series winner, once decided
+	Draw    bool            // the series ended with more than one leader
+	Rematch map[string]bool // players asking for the next game after the game is over
+}
+
+func NewSeries(bestOf int) *Series {
+	return &Series{
+		BestOf:  bestOf,
+		Round:   1,
+		Score:   make(map[string]int),
+		Rematch: make(map[string]bool),
+	}
+}
+
+// Record counts the game won by the player, empty winner is nobody's win.
+// The player who wins more than half of BestOf games wins the series. With
+// draws or more than two players nobody may get there, the leader after the
+// last game wins then, or the series is a draw.
+func (s *Series) Record(winner string) {
+	if winner != "" {
+		s.Score[winner]++
+		if s.BestOf > 0 && s.Score[winner] > s.BestOf/2 {
+			s.Winner = winner
+		}
+	}
+	if s.BestOf > 0 && s.Winner == "" && s.Round >= s.BestOf {
+		s.Winner, s.Draw = s.leader()
+	}
+}
+
+// leader returns the player with the most games won, draw if there is no
+// single one
+func (s *Series) leader() (string, bool) {
+	var leader string
+	best, draw := 0, true
+	for id, score := range s.Score {
+		switch {
+		case score > best:
+			leader, best, draw = id, score, false
+		case score == best:
+			draw = true
+		}
+	}
+	if draw {
+		return "", true
+	}
+	return leader, false
+}
+
+// IsOver reports whether the series is decided
+func (s *Series) IsOver() bool {
+	return s.Winner != "" || s.Draw
+}
+
+// Next moves the series to the next game, a new series starts after the
+// decided one
+func (s *Series) Next() {
+	s.Round++
+	s.Rematch = make(map[string]bool)
+	if s.IsOver() {
+		s.Round = 1
+		s.Score = make(map[string]int)
+		s.Winner = ""
+		s.Draw = false
+	}
+}
+
+// String describes the series score, e.g. "Round 3 of 5, P1 2, P2 0"
+func (s *Series) String() string {
+	round := fmt.Sprintf("Round %d", s.Round)
+	if s.BestOf > 0 {
+		round += fmt.Sprintf(" of %d", s.BestOf)
+	}
+
+	ids := make([]string, 0, len(s.Score))
+	for id := range s.Score {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	scores := make([]string, 0, len(ids))
+	for _, id := range ids {
+		scores = append(scores, fmt.Sprintf("%s %d", id, s.Score[id]))
+	}
+	if len(scores) == 0 {
+		return round
+	}
+	return round + ", " + strings.Join(scores, ", ")
+}
+
+// Loser returns the player who lost the finished game: the first one out of
+// the game, or the one after the winner if everybody else cleared the field
+// along with the winner.
+func (m *Model) Loser() string {
+	if len(m.Eliminated) > 0 {
+		return m.Eliminated[0]
+	}
+	if m.Winner != "" {
+		return m.NextPlayer(m.Winner)
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package game
+
+import "testing"
+
+func TestSeries(t *testing.T) {
+	tests := []struct {
+		name    string
+		bestOf  int
+		winners []string // of the games played, empty for a draw
+		want    string
+		draw    bool
+	}{
+		{name: "majority", bestOf: 5, winners: []string{"P1", "P2", "P1", "P1"}, want: "P1"},
+		{name: "not decided yet", bestOf: 5, winners: []string{"P1", "P2", "P3"}},
+		{name: "leader after draws", bestOf: 3, winners: []string{"P1", "", ""}, want: "P1"},
+		{name: "leader of three players", bestOf: 5, winners: []string{"P1", "P2", "P1", "P3", ""}, want: "P1"},
+		{name: "shared lead", bestOf: 3, winners: []string{"P1", "P2", "P3"}, draw: true},
+		{name: "all draws", bestOf: 3, winners: []string{"", "", ""}, draw: true},
+		{name: "endless", winners: []string{"P1", "P1", "P1", "P1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSeries(tt.bestOf)
+			for i, w := range tt.winners {
+				if i > 0 {
+					s.Next()
+				}
+				s.Record(w)
+			}
+			if s.Winner != tt.want || s.Draw != tt.draw {
+				t.Errorf("Winner = %q, Draw = %v, want %q, %v", s.Winner, s.Draw, tt.want, tt.draw)
+			}
+			if tt.bestOf > 0 && s.Round > tt.bestOf {
+				t.Errorf("Round = %d of %d", s.Round, tt.bestOf)
+			}
+		})
+	}
+}
+
+func TestSeriesNextAfterDraw(t *testing.T) {
+	s := NewSeries(1)
+	s.Record("")
+	if !s.IsOver() {
+		t.Fatal("the only game is a draw, but the series isn't over")
+	}
+
+	s.Next()
+	if s.IsOver() || s.Round != 1 || len(s.Score) != 0 {
+		t.Errorf("Next() after the draw = %s, over %v, want a new series", s, s.IsOver())
+	}
+}
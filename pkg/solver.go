@@ -0,0 +1,202 @@
+package game
+
+// maxNoGuessAttempts limits how many boards are generated looking for one
+// that can be solved without guessing
+const maxNoGuessAttempts = 1000
+
+// noGuessWork limits the deduction work of all attempts, cells and pairs of
+// constraints looked at, big boards take long to solve. It's counted in work
+// rather than time, so a seed gives the same board on any machine. It takes
+// about 2 seconds, HARD boards need a tenth of it.
+const noGuessWork = 20_000_000
+
+// MaxNoGuessDensity is the share of mines above which a no-guess board is
+// rarely found in maxNoGuessAttempts, HARD has about 0.21
+const MaxNoGuessDensity = 0.22
+
+// solver replays a game on a board with known Mines using deduction only.
+// known holds what a player could see: HIDE, FLAG for deduced mines or the
+// opened value from Mines.
+type solver struct {
+	m     *Model
+	known [][]rune
+
+	left, flags int
+	work        int // deduction work done
+}
+
+func newSolver(m *Model) *solver {
+	known := make([][]rune, m.N)
+	for r := range known {
+		known[r] = make([]rune, m.M)
+		for c := range known[r] {
+			known[r][c] = HIDE
+		}
+	}
+	return &solver{
+		m:     m,
+		known: known,
+		left:  m.N*m.M - m.MinesCount,
+	}
+}
+
+// IsSolvable reports whether the board can be fully opened from the first
+// cell without a single forced guess
+func IsSolvable(m *Model, first Point) bool {
+	solved, _ := solve(m, first, -1)
+	return solved
+}
+
+// solve is IsSolvable giving up once the work is over the budget, negative
+// for no limit. Returns the work done.
+func solve(m *Model, first Point, budget int) (bool, int) {
+	s := newSolver(m)
+	if m.Mines[first[0]][first[1]] == MINE {
+		return false, 0
+	}
+	s.open(first)
+
+	for s.left > 0 {
+		if budget >= 0 && s.work > budget {
+			return false, s.work
+		}
+		if !s.step() {
+			return false, s.work
+		}
+	}
+	return true, s.work
+}
+
+func (s *solver) open(p Point) {
+	if s.known[p[0]][p[1]] != HIDE {
+		return
+	}
+	s.known[p[0]][p[1]] = s.m.Mines[p[0]][p[1]]
+	s.left--
+	if s.m.Mines[p[0]][p[1]] == ZERO {
+		for _, n := range s.m.Neighbours(p) {
+			s.open(n)
+		}
+	}
+}
+
+func (s *solver) flag(p Point) {
+	if s.known[p[0]][p[1]] == HIDE {
+		s.known[p[0]][p[1]] = FLAG
+		s.flags++
+	}
+}
+
+// constraint is "exactly mines of cells are mines"
+type constraint struct {
+	cells []Point
+	mines int
+}
+
+// constraints collects one constraint for each opened number with hidden neighbours
+func (s *solver) constraints() []constraint {
+	var cs []constraint
+	for r := 0; r < s.m.N; r++ {
+		for c := 0; c < s.m.M; c++ {
+			v := s.known[r][c]
+			if v == HIDE || v == FLAG || v == ZERO {
+				continue
+			}
+			con := constraint{mines: int(v - ZERO)}
+			for _, n := range s.m.Neighbours(Point{r, c}) {
+				switch s.known[n[0]][n[1]] {
+				case HIDE:
+					con.cells = append(con.cells, n)
+				case FLAG:
+					con.mines--
+				}
+			}
+			if len(con.cells) > 0 {
+				cs = append(cs, con)
+			}
+		}
+	}
+	return cs
+}
+
+// step applies the deduction rules once and reports if anything was resolved
+func (s *solver) step() bool {
+	progress := false
+	apply := func(cells []Point, mines int) {
+		switch mines {
+		case 0:
+			for _, p := range cells {
+				s.open(p)
+			}
+			progress = true
+		case len(cells):
+			for _, p := range cells {
+				s.flag(p)
+			}
+			progress = true
+		}
+	}
+
+	// single cell rules
+	cs := s.constraints()
+	s.work += s.m.N * s.m.M
+	for _, con := range cs {
+		apply(con.cells, con.mines)
+	}
+	if progress {
+		return true
+	}
+
+	// subset rule: if A ⊆ B then B \ A contains B.mines - A.mines mines
+	s.work += len(cs) * len(cs)
+	for i, a := range cs {
+		for j, b := range cs {
+			if i == j || len(a.cells) >= len(b.cells) {
+				continue
+			}
+			if diff, ok := subtract(b.cells, a.cells); ok {
+				apply(diff, b.mines-a.mines)
+			}
+		}
+	}
+	if progress {
+		return true
+	}
+
+	// global rule: the mines counter resolves the rest of the board
+	s.work += s.m.N * s.m.M
+	var hidden []Point
+	for r := 0; r < s.m.N; r++ {
+		for c := 0; c < s.m.M; c++ {
+			if s.known[r][c] == HIDE {
+				hidden = append(hidden, Point{r, c})
+			}
+		}
+	}
+	if len(hidden) > 0 {
+		apply(hidden, s.m.MinesCount-s.flags)
+	}
+
+	return progress
+}
+
+// subtract returns b \ a if a is a subset of b
+func subtract(b, a []Point) ([]Point, bool) {
+	in := make(map[Point]bool, len(b))
+	for _, p := range b {
+		in[p] = true
+	}
+	for _, p := range a {
+		if !in[p] {
+			return nil, false
+		}
+		delete(in, p)
+	}
+	diff := make([]Point, 0, len(in))
+	for _, p := range b {
+		if in[p] {
+			diff = append(diff, p)
+		}
+	}
+	return diff, true
+}
@@ -0,0 +1,139 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+// layout makes a placed board from rows of '*' for mines and '.' for safe cells
+func layout(rows ...string) *Model {
+	m := NewModel(len(rows), len(rows[0]), strings.Count(strings.Join(rows, ""), "*"), 1, true, false)
+	for r, row := range rows {
+		for c, v := range row {
+			if v == '*' {
+				m.Mines[r][c] = MINE
+			}
+		}
+	}
+	for r := range rows {
+		for c := range rows[r] {
+			if m.Mines[r][c] != MINE {
+				continue
+			}
+			for _, n := range m.Neighbours(Point{r, c}) {
+				if m.Mines[n[0]][n[1]] != MINE {
+					m.Mines[n[0]][n[1]]++
+				}
+			}
+		}
+	}
+	m.Placed = true
+	return &m
+}
+
+func TestIsSolvable(t *testing.T) {
+	tests := []struct {
+		name  string
+		rows  []string
+		first Point
+		want  bool
+	}{
+		{
+			name:  "single cell rules",
+			rows:  []string{"*..", "...", "..."},
+			first: Point{2, 2},
+			want:  true,
+		},
+		{
+			// 1 1 2 1 1 under the hidden row: only pairs of the numbers place the mines
+			name:  "subset rule",
+			rows:  []string{".*.*.", ".....", "....."},
+			first: Point{2, 0},
+			want:  true,
+		},
+		{
+			// both 1s see the same two hidden cells
+			name:  "forced 50/50",
+			rows:  []string{"..*", "..."},
+			first: Point{1, 0},
+			want:  false,
+		},
+		{
+			name:  "mine at the first cell",
+			rows:  []string{"*..", "...", "..."},
+			first: Point{0, 0},
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSolvable(layout(tt.rows...), tt.first); got != tt.want {
+				t.Errorf("IsSolvable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSolverNeedsSubsetRule(t *testing.T) {
+	s := newSolver(layout(".*.*.", ".....", "....."))
+	s.open(Point{2, 0})
+
+	for _, con := range s.constraints() {
+		if con.mines == 0 || con.mines == len(con.cells) {
+			t.Fatalf("constraint %v is resolved without the subset rule", con)
+		}
+	}
+	if !s.step() {
+		t.Fatal("step() made no progress")
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	b := []Point{{0, 0}, {0, 1}, {0, 2}}
+
+	diff, ok := subtract(b, []Point{{0, 0}, {0, 1}})
+	if !ok || len(diff) != 1 || diff[0] != (Point{0, 2}) {
+		t.Errorf("subtract() = %v, %v, want [[0 2]], true", diff, ok)
+	}
+	if _, ok := subtract(b, []Point{{0, 1}, {1, 1}}); ok {
+		t.Error("subtract() of not a subset reports ok")
+	}
+}
+
+func TestPlaceMinesGivesUp(t *testing.T) {
+	// the only mine is next to the opening, every layout is a 50/50
+	m := NewModel(2, 3, 1, 1, true, false)
+	m.placeMines(Point{1, 0})
+	if !m.Guessing {
+		t.Error("no-guess board found where there is none")
+	}
+
+	if testing.Short() {
+		t.Skip("the work limit takes seconds")
+	}
+	rows, cols := 99, 99
+	m = NewModel(rows, cols, int(MaxNoGuessDensity*float64(rows*cols)), 1, true, false)
+	m.placeMines(Point{rows / 2, cols / 2})
+	if !m.Placed {
+		t.Error("big board isn't laid out")
+	}
+}
+
+func TestLayoutIsSeeded(t *testing.T) {
+	s := Settings{Difficulty: HARD, NoGuess: true, Seed: 42}
+	first := Point{4, 4}
+
+	opened := NewGame(s, false)
+	opened.OpenCell(first, "P1")
+	laid := NewGame(s, false)
+	laid.Place(laid.Layout(first))
+
+	if !laid.M.Placed || laid.First != first {
+		t.Fatalf("Place() laid out %v around %v", laid.M.Placed, laid.First)
+	}
+	for r := range opened.M.Mines {
+		if string(opened.M.Mines[r]) != string(laid.M.Mines[r]) {
+			t.Fatalf("row %d: %q laid out, %q on the first move", r, string(laid.M.Mines[r]), string(opened.M.Mines[r]))
+		}
+	}
+}
@@ -3,6 +3,7 @@ package game
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/json"
 	"log"
 )
 
@@ -17,13 +18,30 @@ func ToGob[T any](from T) []byte {
 
 }
 
-func FromGob[T any](from []byte, to *T) {
+func FromGob[T any](from []byte, to *T) error {
 	buf := bytes.NewBuffer(from)
 	decoder := gob.NewDecoder(buf)
 	err := decoder.Decode(to)
 	if err != nil {
 		log.Printf("can't convert from gob: %s", err.Error())
 	}
+	return err
+}
+
+func ToJSON[T any](from T) []byte {
+	bs, err := json.Marshal(from)
+	if err != nil {
+		log.Printf("can't convert to json: %s", err.Error())
+	}
+	return bs
+}
+
+func FromJSON[T any](from []byte, to *T) error {
+	err := json.Unmarshal(from, to)
+	if err != nil {
+		log.Printf("can't convert from json: %s", err.Error())
+	}
+	return err
 }
 
 // ReverseStrings reverses a slice of strings in place
@@ -32,3 +50,15 @@ func ReverseStrings(xs []string) {
 		xs[i], xs[len(xs)-1-i] = xs[len(xs)-1-i], xs[i]
 	}
 }
+
+// copyMap returns a shallow copy of the map, nil for nil
+func copyMap[K comparable, V any](from map[K]V) map[K]V {
+	if from == nil {
+		return nil
+	}
+	to := make(map[K]V, len(from))
+	for k, v := range from {
+		to[k] = v
+	}
+	return to
+}